@@ -0,0 +1,39 @@
+package tmx
+
+// Duration returns the total duration of the animation in milliseconds, i.e. the
+// sum of the durations of its frames.
+func (a Animation) Duration() int {
+	total := 0
+	for _, f := range a.Frames {
+		total += f.Duration
+	}
+	return total
+}
+
+// FrameCount returns the number of frames of the animation.
+func (a Animation) FrameCount() int {
+	return len(a.Frames)
+}
+
+// FrameAt returns the frame displayed at the given time in milliseconds since
+// the start of the animation. The animation is played in a loop, so times past
+// its duration wrap around. The boolean result is false if the animation has no
+// frames or a total duration of 0.
+func (a Animation) FrameAt(ms int) (Frame, bool) {
+	total := a.Duration()
+	if total <= 0 {
+		return Frame{}, false
+	}
+	t := ms % total
+	if t < 0 {
+		t += total
+	}
+	for _, f := range a.Frames {
+		if t < f.Duration {
+			return f, true
+		}
+		t -= f.Duration
+	}
+	// Unreachable, unless some frames have negative durations.
+	return a.Frames[len(a.Frames)-1], true
+}
@@ -0,0 +1,73 @@
+package tmx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnimation(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="4">
+  <tile id="0">
+   <animation>
+    <frame tileid="0" duration="100"/>
+    <frame tileid="1" duration="200"/>
+    <frame tileid="3" duration="50"/>
+   </animation>
+  </tile>
+  <tile id="1"/>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := m.Tilesets[0]
+	a := ts.TilesInfo[0].Animation
+	if got := a.FrameCount(); got != 3 {
+		t.Errorf("frame count mismatch; expected 3, got %d", got)
+	}
+	if got := a.Duration(); got != 350 {
+		t.Errorf("duration mismatch; expected 350, got %d", got)
+	}
+	golden := []struct {
+		ms   int
+		want int
+	}{
+		{ms: 0, want: 0},
+		{ms: 99, want: 0},
+		{ms: 100, want: 1},
+		{ms: 299, want: 1},
+		{ms: 300, want: 3},
+		{ms: 349, want: 3},
+		// The animation is played in a loop.
+		{ms: 350, want: 0},
+		{ms: 3*350 + 150, want: 1},
+		{ms: -1, want: 3},
+		{ms: -350, want: 0},
+	}
+	for _, g := range golden {
+		f, ok := a.FrameAt(g.ms)
+		if !ok {
+			t.Errorf("%d ms: unable to locate frame", g.ms)
+			continue
+		}
+		if f.TileID != g.want {
+			t.Errorf("%d ms: tile ID mismatch; expected %d, got %d", g.ms, g.want, f.TileID)
+		}
+	}
+	// Tiles which are not animated have no frames.
+	b := ts.TilesInfo[1].Animation
+	if b.FrameCount() != 0 || b.Duration() != 0 {
+		t.Errorf("expected animation without frames, got %d frames of %d ms", b.FrameCount(), b.Duration())
+	}
+	if _, ok := b.FrameAt(0); ok {
+		t.Error("expected no frame of animation without frames")
+	}
+	// Animations with a total duration of 0 have no frame to display.
+	c := Animation{Frames: []Frame{{TileID: 1}, {TileID: 2}}}
+	if _, ok := c.FrameAt(0); ok {
+		t.Error("expected no frame of animation with a duration of 0")
+	}
+}
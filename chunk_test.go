@@ -0,0 +1,175 @@
+package tmx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// badChunkMap is an infinite map with a 2x2 chunk which contains 3 GIDs.
+const badChunkMap = `
+<map version="1.2" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32" infinite="1">
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">
+   <chunk x="0" y="0" width="2" height="2">1,2,3</chunk>
+  </data>
+ </layer>
+</map>`
+
+func TestChunkDecodeError(t *testing.T) {
+	_, err := NewFile(strings.NewReader(badChunkMap), WithEagerChunks())
+	if err == nil {
+		t.Fatal("expected error for chunk with wrong number of GIDs")
+	}
+}
+
+func TestChunkDecodeErrorLazy(t *testing.T) {
+	// Chunks are decoded on first access by default.
+	m, err := NewFile(strings.NewReader(badChunkMap))
+	if err != nil {
+		t.Fatalf("unexpected error while parsing; %v", err)
+	}
+	if err := m.Layers[0].Decode(); err == nil {
+		t.Error("expected error while decoding")
+	}
+}
+
+func TestChunkDecodeOnAccess(t *testing.T) {
+	src := chunkedMapSource(2, 1, "base64", "zlib")
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := m.Layers[0].Data.Chunks
+	for i := range chunks {
+		if chunks[i].grid.gids != nil {
+			t.Errorf("chunk %d decoded while parsing", i)
+		}
+	}
+	// Only the accessed chunk is decoded.
+	if got := m.Layers[0].GetGID(0, 0); got != 1 {
+		t.Errorf("GID mismatch; expected 1, got %d", got)
+	}
+	if chunks[0].grid.gids == nil {
+		t.Error("accessed chunk not decoded")
+	}
+	if chunks[1].grid.gids != nil {
+		t.Error("chunk decoded without being accessed")
+	}
+	// Every chunk is decoded while parsing with eager chunk decoding.
+	m, err = NewFile(strings.NewReader(src), WithEagerChunks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks = m.Layers[0].Data.Chunks
+	for i := range chunks {
+		if chunks[i].grid.gids == nil {
+			t.Errorf("chunk %d not decoded while parsing", i)
+		}
+	}
+}
+
+func TestChunkLazyConcurrent(t *testing.T) {
+	// Out of range GIDs are recovered from in lenient mode, which records
+	// warnings while the chunks are decoded on demand.
+	src := chunkedMapSource(4, 4, "csv", "")
+	src = strings.Replace(src, ">1,", ">99999999999,", -1)
+	m, err := NewFile(strings.NewReader(src), WithLenient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, c := range l.Data.Chunks {
+				l.GetGID(c.X, c.Y)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := len(m.Warnings), 16; got != want {
+		t.Errorf("number of warnings mismatch; expected %d, got %d", want, got)
+	}
+}
+
+// chunkedMapSource returns the source of an infinite map with a single tile
+// layer of cols by rows chunks of 16x16 tiles, using the given encoding and
+// compression.
+func chunkedMapSource(cols, rows int, encoding, compression string) string {
+	const size = 16
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<map version="1.2" orientation="orthogonal" width="%d" height="%d" tilewidth="32" tileheight="32" infinite="1">`, cols*size, rows*size)
+	fmt.Fprintf(buf, `<layer name="ground"><data encoding="%s" compression="%s">`, encoding, compression)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			gids := make([]uint32, size*size)
+			for i := range gids {
+				gids[i] = uint32(i%7 + 1)
+			}
+			fmt.Fprintf(buf, `<chunk x="%d" y="%d" width="%d" height="%d">`, x*size, y*size, size, size)
+			buf.WriteString(encodeGIDs(gids, encoding, compression))
+			buf.WriteString("</chunk>")
+		}
+	}
+	buf.WriteString("</data></layer></map>")
+	return buf.String()
+}
+
+// encodeGIDs encodes the GIDs using the given encoding and compression.
+func encodeGIDs(gids []uint32, encoding, compression string) string {
+	if encoding == "csv" {
+		var fields []string
+		for _, gid := range gids {
+			fields = append(fields, fmt.Sprint(gid))
+		}
+		return strings.Join(fields, ",")
+	}
+	raw := &bytes.Buffer{}
+	binary.Write(raw, binary.LittleEndian, gids)
+	switch compression {
+	case "gzip":
+		compressed := &bytes.Buffer{}
+		z := gzip.NewWriter(compressed)
+		z.Write(raw.Bytes())
+		z.Close()
+		raw = compressed
+	case "zlib":
+		compressed := &bytes.Buffer{}
+		z := zlib.NewWriter(compressed)
+		z.Write(raw.Bytes())
+		z.Close()
+		raw = compressed
+	}
+	return base64.StdEncoding.EncodeToString(raw.Bytes())
+}
+
+func BenchmarkChunkDecodeEager(b *testing.B) {
+	benchmarkChunkDecode(b, WithEagerChunks())
+}
+
+func BenchmarkChunkDecodeLazy(b *testing.B) {
+	benchmarkChunkDecode(b)
+}
+
+// benchmarkChunkDecode benchmarks parsing an infinite map with many chunks,
+// and accessing a single tile.
+func benchmarkChunkDecode(b *testing.B, opts ...Option) {
+	src := chunkedMapSource(32, 32, "base64", "zlib")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m, err := NewFile(strings.NewReader(src), opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m.Layers[0].GetGID(0, 0)
+	}
+}
@@ -0,0 +1,44 @@
+package tmx
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// A Color is a non-alpha-premultiplied color, as stored in tmx files. It
+// implements the color.Color interface.
+type Color struct {
+	R, G, B, A uint8
+}
+
+// RGBA returns the alpha-premultiplied red, green, blue and alpha values of the
+// color.
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: c.A}.RGBA()
+}
+
+// ParseColor parses a color in the "#AARRGGBB" or "#RRGGBB" format used by tmx
+// files. The leading '#' is optional, and colors without alpha are opaque.
+func ParseColor(s string) (Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return Color{}, fmt.Errorf("ParseColor: invalid color '%s'.", s)
+	}
+	x, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("ParseColor: invalid color '%s'.", s)
+	}
+	if len(hex) == 6 {
+		// Colors without alpha are opaque.
+		x |= 0xFF000000
+	}
+	c := Color{
+		A: uint8(x >> 24),
+		R: uint8(x >> 16),
+		G: uint8(x >> 8),
+		B: uint8(x),
+	}
+	return c, nil
+}
@@ -0,0 +1,39 @@
+package tmx
+
+import "testing"
+
+func TestParseColor(t *testing.T) {
+	golden := []struct {
+		s    string
+		want Color
+	}{
+		{s: "#ff0000", want: Color{R: 0xFF, A: 0xFF}},
+		{s: "00ff00", want: Color{G: 0xFF, A: 0xFF}},
+		{s: "#800000FF", want: Color{B: 0xFF, A: 0x80}},
+		{s: "#00000000", want: Color{}},
+	}
+	for _, g := range golden {
+		got, err := ParseColor(g.s)
+		if err != nil {
+			t.Errorf("%q: unexpected error; %v", g.s, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%q: color mismatch; expected %v, got %v", g.s, g.want, got)
+		}
+	}
+	for _, s := range []string{"", "#", "#fff", "#ff00000", "#gg0000", "#-10000"} {
+		if _, err := ParseColor(s); err == nil {
+			t.Errorf("%q: expected error for invalid color", s)
+		}
+	}
+}
+
+func TestColorRGBA(t *testing.T) {
+	// The color is not alpha-premultiplied.
+	c := Color{R: 0xFF, A: 0x80}
+	r, g, b, a := c.RGBA()
+	if r != 0x8080 || g != 0 || b != 0 || a != 0x8080 {
+		t.Errorf("RGBA mismatch; expected (0x8080, 0, 0, 0x8080), got (%#x, %#x, %#x, %#x)", r, g, b, a)
+	}
+}
@@ -0,0 +1,41 @@
+package tmx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorList is a list of errors.
+type ErrorList []error
+
+// Error returns the error messages of the list, separated by newlines.
+func (list ErrorList) Error() string {
+	var msgs []string
+	for _, err := range list {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// errorBudget is the maximum number of errors recorded per map in lenient mode,
+// which keeps memory usage and output bounded for badly broken maps.
+const errorBudget = 100
+
+// warn records an error recovered from in lenient mode. Once the error budget
+// has been exhausted, the remaining errors are summarized by a final entry.
+func (conf *config) warn(err error) {
+	conf.mu.Lock()
+	defer conf.mu.Unlock()
+	conf.nwarnings++
+	if conf.warnings == nil {
+		return
+	}
+	switch {
+	case conf.nwarnings <= errorBudget:
+		*conf.warnings = append(*conf.warnings, err)
+	case conf.nwarnings == errorBudget+1:
+		*conf.warnings = append(*conf.warnings, fmt.Errorf("and 1 more error."))
+	default:
+		(*conf.warnings)[errorBudget] = fmt.Errorf("and %d more errors.", conf.nwarnings-errorBudget)
+	}
+}
@@ -0,0 +1,60 @@
+package tmx
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLenientErrorBudget(t *testing.T) {
+	golden := []struct {
+		// Number of invalid GIDs in the layer data.
+		invalid int
+		// Expected number of warnings.
+		n int
+		// Expected final warning.
+		last string
+	}{
+		{invalid: 1, n: 1, last: "decodeCsv: invalid GID '99999999999' at (0, 0) replaced by 0."},
+		{invalid: 100, n: 100, last: "decodeCsv: invalid GID '99999999999' at (99, 0) replaced by 0."},
+		{invalid: 101, n: 101, last: "and 1 more error."},
+		{invalid: 150, n: 101, last: "and 50 more errors."},
+	}
+	for _, g := range golden {
+		// A single row of GIDs out of range, followed by a valid GID.
+		fields := make([]string, g.invalid+1)
+		for i := 0; i < g.invalid; i++ {
+			fields[i] = "99999999999"
+		}
+		fields[g.invalid] = "1"
+		src := fmt.Sprintf(`
+<map version="1.0" orientation="orthogonal" width="%d" height="1" tilewidth="32" tileheight="32">
+ <layer name="ground" width="%d" height="1">
+  <data encoding="csv">%s</data>
+ </layer>
+</map>`, len(fields), len(fields), strings.Join(fields, ","))
+		if _, err := NewFile(strings.NewReader(src)); err == nil {
+			t.Errorf("%d invalid GIDs: expected error in strict mode", g.invalid)
+		}
+		m, err := NewFile(strings.NewReader(src), WithLenient())
+		if err != nil {
+			t.Errorf("%d invalid GIDs: unexpected error; %v", g.invalid, err)
+			continue
+		}
+		if len(m.Warnings) != g.n {
+			t.Errorf("%d invalid GIDs: number of warnings mismatch; expected %d, got %d", g.invalid, g.n, len(m.Warnings))
+			continue
+		}
+		if got := m.Warnings[g.n-1].Error(); got != g.last {
+			t.Errorf("%d invalid GIDs: final warning mismatch; expected %q, got %q", g.invalid, g.last, got)
+		}
+		// Invalid GIDs are replaced by the empty GID 0.
+		l := &m.Layers[0]
+		if gid := l.GetGID(0, 0); gid != 0 {
+			t.Errorf("%d invalid GIDs: GID mismatch of invalid GID; expected 0, got %d", g.invalid, gid)
+		}
+		if gid := l.GetGID(g.invalid, 0); gid != 1 {
+			t.Errorf("%d invalid GIDs: GID mismatch of valid GID; expected 1, got %d", g.invalid, gid)
+		}
+	}
+}
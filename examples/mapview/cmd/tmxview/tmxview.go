@@ -5,10 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path"
 
-	"github.com/mewkiz/pkg/imgutil"
-	"github.com/mewspring/tmx"
 	"github.com/mewspring/tmx/examples/mapview"
 )
 
@@ -35,26 +32,9 @@ func usage() {
 func main() {
 	flag.Parse()
 	for _, tmxPath := range flag.Args() {
-		err := view(tmxPath)
+		err := mapview.RenderToFile(tmxPath, pngPath)
 		if err != nil {
 			log.Fatalln(err)
 		}
 	}
 }
-
-func view(tmxPath string) (err error) {
-	m, err := tmx.Open(tmxPath)
-	if err != nil {
-		return err
-	}
-	view, err := mapview.NewView(m, path.Dir(tmxPath))
-	if err != nil {
-		return err
-	}
-	view.Draw()
-	err = imgutil.WriteFile(pngPath, view)
-	if err != nil {
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,79 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// DrawGrid draws the cell boundaries of the map to the view image, using the
+// provided color. The cells of orthogonal maps are drawn as rectangles, while
+// the cells of isometric and staggered maps are drawn as diamonds.
+func (view *View) DrawGrid(c color.Color) {
+	for row := 0; row < view.rows; row++ {
+		for col := 0; col < view.cols; col++ {
+			r := view.GetCellRect(col, row).Add(view.origin)
+			// The rectangle is half-open, so the last pixel is at Max-1.
+			minX, minY := r.Min.X, r.Min.Y
+			maxX, maxY := r.Max.X-1, r.Max.Y-1
+			var corners []image.Point
+			if view.orientation == "orthogonal" {
+				corners = []image.Point{
+					{minX, minY},
+					{maxX, minY},
+					{maxX, maxY},
+					{minX, maxY},
+				}
+			} else {
+				midX, midY := (minX+maxX)/2, (minY+maxY)/2
+				corners = []image.Point{
+					{midX, minY},
+					{maxX, midY},
+					{midX, maxY},
+					{minX, midY},
+				}
+			}
+			for i, p := range corners {
+				drawLine(view, p, corners[(i+1)%len(corners)], c)
+			}
+		}
+	}
+}
+
+// drawLine draws a line from p to q, inclusive, using Bresenham's line
+// algorithm.
+func drawLine(dst draw.Image, p, q image.Point, c color.Color) {
+	dx, dy := abs(q.X-p.X), -abs(q.Y-p.Y)
+	sx, sy := 1, 1
+	if p.X > q.X {
+		sx = -1
+	}
+	if p.Y > q.Y {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		dst.Set(p.X, p.Y, c)
+		if p == q {
+			return
+		}
+		// Both steps are decided using the error before either step is taken.
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			p.X += sx
+		}
+		if e2 <= dx {
+			e += dx
+			p.Y += sy
+		}
+	}
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,84 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// white is the color of the grid lines.
+var white = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+
+func TestViewDrawGridOrthogonal(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+</map>`
+	view := newTestView(t, src)
+	view.DrawGrid(white)
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), white},
+		{image.Pt(8, 0), white},
+		{image.Pt(15, 15), white},
+		{image.Pt(0, 8), white},
+		{image.Pt(16, 8), white},
+		{image.Pt(31, 15), white},
+		// The interior of the cells is left untouched.
+		{image.Pt(8, 8), color.RGBA{}},
+		{image.Pt(24, 8), color.RGBA{}},
+	})
+}
+
+func TestViewDrawGridIsometric(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="isometric" width="1" height="1" tilewidth="32" tileheight="16">` + testTileset + `
+</map>`
+	view := newTestView(t, src)
+	view.DrawGrid(white)
+	r := view.GetCellRect(0, 0).Add(view.origin)
+	min, max := r.Min, r.Max.Sub(image.Pt(1, 1))
+	mid := min.Add(max).Div(2)
+	checkPixels(t, view, []pixel{
+		// The corners of the diamond.
+		{image.Pt(mid.X, min.Y), white},
+		{image.Pt(max.X, mid.Y), white},
+		{image.Pt(mid.X, max.Y), white},
+		{image.Pt(min.X, mid.Y), white},
+		// The corners of the cell rectangle are outside of the diamond.
+		{min, color.RGBA{}},
+		{max, color.RGBA{}},
+		{mid, color.RGBA{}},
+	})
+}
+
+func TestDrawLine(t *testing.T) {
+	golden := []struct {
+		p, q image.Point
+		want []image.Point
+	}{
+		{p: image.Pt(0, 0), q: image.Pt(3, 0), want: []image.Point{{0, 0}, {1, 0}, {2, 0}, {3, 0}}},
+		{p: image.Pt(2, 3), q: image.Pt(2, 1), want: []image.Point{{2, 3}, {2, 2}, {2, 1}}},
+		{p: image.Pt(3, 3), q: image.Pt(0, 0), want: []image.Point{{3, 3}, {2, 2}, {1, 1}, {0, 0}}},
+		{p: image.Pt(0, 0), q: image.Pt(3, 1), want: []image.Point{{0, 0}, {1, 0}, {2, 1}, {3, 1}}},
+		{p: image.Pt(1, 1), q: image.Pt(1, 1), want: []image.Point{{1, 1}}},
+	}
+	for _, g := range golden {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		drawLine(img, g.p, g.q, white)
+		n := 0
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				if img.RGBAAt(x, y) == white {
+					n++
+				}
+			}
+		}
+		if n != len(g.want) {
+			t.Errorf("%v-%v: number of pixels mismatch; expected %d, got %d", g.p, g.q, len(g.want), n)
+		}
+		for _, p := range g.want {
+			if img.RGBAAt(p.X, p.Y) != white {
+				t.Errorf("%v-%v: expected pixel at %v", g.p, g.q, p)
+			}
+		}
+	}
+}
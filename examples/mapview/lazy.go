@@ -0,0 +1,169 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"github.com/mewspring/tmx"
+)
+
+const (
+	// blockSize is the width and height in pixels of the blocks rendered by a
+	// lazy view.
+	blockSize = 128
+	// blockCacheSize is the maximum number of rendered blocks kept by a lazy
+	// view.
+	blockCacheSize = 64
+)
+
+// A LazyView is an image representation of a map whose pixels are rendered on
+// demand. The view image is divided into blocks of pixels, and each block is
+// rendered the first time one of its pixels is requested. A small number of
+// rendered blocks are cached, which makes it possible to stream the image
+// representation of very large maps through image encoders without allocating
+// the entire view image.
+//
+// The tile layers are drawn like DrawRegion draws them.
+//
+// Note: Object layers are not drawn.
+type LazyView struct {
+	// view holds the geometry and tileset of the map; its view image is unused.
+	view *View
+	// bounds of the view image.
+	bounds image.Rectangle
+	// margin is the maximum number of pixels a tile may extend past the
+	// rectangle of its cell.
+	margin int
+	// mu protects the cache.
+	mu sync.Mutex
+	// cache is a map from the top-left corner of a block to the rendered block.
+	cache map[image.Point]*image.RGBA
+	// queue contains the corners of the cached blocks, in the order they were
+	// rendered.
+	queue []image.Point
+}
+
+// NewLazyView returns a new lazy view of the map. The tileset sprite sheet is
+// loaded relative to the tmx dir.
+func NewLazyView(m *tmx.Map, dir string) (*LazyView, error) {
+	view, width, height, err := newView(m, dir)
+	if err != nil {
+		return nil, err
+	}
+	lv := &LazyView{
+		view:   view,
+		bounds: image.Rect(0, 0, width, height),
+		margin: getMargin(m),
+		cache:  make(map[image.Point]*image.RGBA),
+	}
+	return lv, nil
+}
+
+// getMargin returns the maximum number of pixels a tile of the map may extend
+// past the rectangle of its cell, based on the tile sizes and tile offsets of
+// all tilesets.
+func getMargin(m *tmx.Map) int {
+	var margin int
+	for _, ts := range m.Tilesets {
+		margin = max(margin, ts.TileWidth+abs(ts.TileOffset.X))
+		margin = max(margin, ts.TileHeight+abs(ts.TileOffset.Y))
+	}
+	return margin
+}
+
+// ColorModel returns the color model of the lazy view.
+func (lv *LazyView) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds returns the bounds of the lazy view.
+func (lv *LazyView) Bounds() image.Rectangle {
+	return lv.bounds
+}
+
+// At returns the color of the pixel at (x, y), rendering the block which
+// contains the pixel if it isn't cached.
+func (lv *LazyView) At(x, y int) color.Color {
+	if !image.Pt(x, y).In(lv.bounds) {
+		return color.RGBA{}
+	}
+	corner := image.Pt(x-x%blockSize, y-y%blockSize)
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+	block, ok := lv.cache[corner]
+	if !ok {
+		block = lv.renderBlock(corner)
+		if len(lv.queue) >= blockCacheSize {
+			delete(lv.cache, lv.queue[0])
+			lv.queue = lv.queue[1:]
+		}
+		lv.cache[corner] = block
+		lv.queue = append(lv.queue, corner)
+	}
+	return block.RGBAAt(x, y)
+}
+
+// renderBlock renders the block with the given top-left corner.
+func (lv *LazyView) renderBlock(corner image.Point) *image.RGBA {
+	r := image.Rect(corner.X, corner.Y, corner.X+blockSize, corner.Y+blockSize).Intersect(lv.bounds)
+	block := image.NewRGBA(r)
+	// Draw to the block instead of the view image; tiles are clipped to the
+	// bounds of the block.
+	view := *lv.view
+	view.Image = block
+	minCol, minRow, maxCol, maxRow := view.cellRange(r.Inset(-lv.margin))
+	view.DrawRegion(minCol, minRow, maxCol, maxRow)
+	return block
+}
+
+// cellRange returns a range of cells which includes every cell whose rectangle
+// in the view image overlaps r. The range may include additional cells.
+func (view *View) cellRange(r image.Rectangle) (minCol, minRow, maxCol, maxRow int) {
+	minCol, minRow = view.cols, view.rows
+	maxCol, maxRow = -1, -1
+	corners := []image.Point{r.Min, {r.Max.X, r.Min.Y}, {r.Min.X, r.Max.Y}, r.Max}
+	for _, p := range corners {
+		col, row := view.cellAt(p.Sub(view.origin))
+		minCol, minRow = min(minCol, col), min(minRow, row)
+		maxCol, maxRow = max(maxCol, col), max(maxRow, row)
+	}
+	// Include neighbouring cells, to account for rounding and for cells which
+	// are shifted by half a tile.
+	return minCol - 1, minRow - 1, maxCol + 1, maxRow + 1
+}
+
+// cellAt returns the approximate coordinates of the cell which contains the
+// given point, relative to the top-left corner of the map. The coordinates may
+// be outside of the map.
+func (view *View) cellAt(p image.Point) (col, row int) {
+	halfTileWidth := view.tileWidth / 2
+	halfTileHeight := view.tileHeight / 2
+	switch view.orientation {
+	case "orthogonal":
+		return floorDiv(p.X, view.tileWidth), floorDiv(p.Y, view.tileHeight)
+	case "staggered":
+		if view.staggerX {
+			return floorDiv(p.X, halfTileWidth), floorDiv(p.Y, view.tileHeight)
+		}
+		return floorDiv(p.X, view.tileWidth), floorDiv(p.Y, halfTileHeight)
+	default:
+		// Invert the calculations of GetCellRect.
+		x := p.X - (view.rows-1)*halfTileWidth
+		col = floorDiv(x*halfTileHeight+p.Y*halfTileWidth, 2*halfTileWidth*halfTileHeight)
+		row = floorDiv(p.Y*halfTileWidth-x*halfTileHeight, 2*halfTileWidth*halfTileHeight)
+		return col, row
+	}
+}
+
+// floorDiv returns x divided by y, rounded towards negative infinity.
+func floorDiv(x, y int) int {
+	if y == 0 {
+		return 0
+	}
+	q := x / y
+	if (x%y != 0) && ((x < 0) != (y < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,177 @@
+package mapview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/tmx"
+)
+
+// lazyMapSource returns the source of a map of the given orientation with a
+// single tile layer of cols by rows tiles, which use all tiles of the sprite
+// sheet written by newTestView. The tileset has the given tile offset.
+func lazyMapSource(orientation, stagger string, cols, rows, tileWidth, tileHeight int, offset image.Point) string {
+	var gids []string
+	for i := 0; i < cols*rows; i++ {
+		gids = append(gids, fmt.Sprint(i%4))
+	}
+	return fmt.Sprintf(`
+<map version="1.0" orientation="%s" %s width="%d" height="%d" tilewidth="%d" tileheight="%d">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="3" columns="3">
+  <tileoffset x="%d" y="%d"/>
+  <image source="tiles.png" width="48" height="16"/>
+ </tileset>
+ <layer name="ground" width="%d" height="%d">
+  <data encoding="csv">%s</data>
+ </layer>
+</map>`, orientation, stagger, cols, rows, tileWidth, tileHeight, offset.X, offset.Y, cols, rows, strings.Join(gids, ","))
+}
+
+func TestLazyView(t *testing.T) {
+	golden := []struct {
+		name       string
+		src        string
+		blockCount int
+	}{
+		{
+			name:       "orthogonal",
+			src:        lazyMapSource("orthogonal", "", 20, 20, 16, 16, image.Pt(0, 0)),
+			blockCount: 9,
+		},
+		{
+			name:       "orthogonal offset",
+			src:        lazyMapSource("orthogonal", "", 20, 20, 16, 16, image.Pt(-5, 7)),
+			blockCount: 9,
+		},
+		{
+			name:       "isometric",
+			src:        lazyMapSource("isometric", "", 12, 10, 32, 16, image.Pt(3, -3)),
+			blockCount: 6,
+		},
+		{
+			name:       "staggered y",
+			src:        lazyMapSource("staggered", `staggeraxis="y" staggerindex="odd"`, 10, 20, 32, 16, image.Pt(0, 0)),
+			blockCount: 6,
+		},
+		{
+			name:       "staggered x",
+			src:        lazyMapSource("staggered", `staggeraxis="x" staggerindex="even"`, 20, 10, 32, 16, image.Pt(0, 0)),
+			blockCount: 6,
+		},
+	}
+	for _, g := range golden {
+		dir := t.TempDir()
+		writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red, green, blue)
+		m, err := tmx.NewFile(strings.NewReader(g.src))
+		if err != nil {
+			t.Fatalf("%s: %v", g.name, err)
+		}
+		view, err := NewView(m, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", g.name, err)
+		}
+		view.Draw()
+		lv, err := NewLazyView(m, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", g.name, err)
+		}
+		if got, want := lv.Bounds(), view.Bounds(); got != want {
+			t.Errorf("%s: bounds mismatch; expected %v, got %v", g.name, want, got)
+			continue
+		}
+		if got, want := len(lv.cache), 0; got != want {
+			t.Errorf("%s: number of cached blocks mismatch; expected %d, got %d", g.name, want, got)
+		}
+		checkSameImage(t, g.name, view, lv)
+		if got, want := len(lv.cache), g.blockCount; got != want {
+			t.Errorf("%s: number of cached blocks mismatch; expected %d, got %d", g.name, want, got)
+		}
+	}
+}
+
+func TestLazyViewCacheSize(t *testing.T) {
+	dir := t.TempDir()
+	writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red, green, blue)
+	src := lazyMapSource("orthogonal", "", 100, 100, 16, 16, image.Pt(0, 0))
+	m, err := tmx.NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(m, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view.Draw()
+	lv, err := NewLazyView(m, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameImage(t, "large map", view, lv)
+	if got, want := len(lv.cache), blockCacheSize; got != want {
+		t.Errorf("number of cached blocks mismatch; expected %d, got %d", want, got)
+	}
+	if got, want := len(lv.queue), blockCacheSize; got != want {
+		t.Errorf("length of block queue mismatch; expected %d, got %d", want, got)
+	}
+	// Pixels of evicted blocks are rendered again.
+	checkSameImage(t, "large map (second pass)", view, lv)
+}
+
+func TestLazyViewOutside(t *testing.T) {
+	dir := t.TempDir()
+	writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red, green, blue)
+	m, err := tmx.NewFile(strings.NewReader(lazyMapSource("orthogonal", "", 2, 2, 16, 16, image.Pt(0, 0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lv, err := NewLazyView(m, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []image.Point{{-1, 0}, {0, -1}, {32, 0}, {0, 32}} {
+		if got, want := lv.At(p.X, p.Y), (color.RGBA{}); got != want {
+			t.Errorf("pixel mismatch at %v; expected %v, got %v", p, want, got)
+		}
+	}
+	if got := len(lv.cache); got != 0 {
+		t.Errorf("number of cached blocks mismatch; expected 0, got %d", got)
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	golden := []struct {
+		x, y, want int
+	}{
+		{x: 7, y: 2, want: 3},
+		{x: -7, y: 2, want: -4},
+		{x: 7, y: -2, want: -4},
+		{x: -7, y: -2, want: 3},
+		{x: -8, y: 2, want: -4},
+		{x: 0, y: 5, want: 0},
+		{x: 5, y: 0, want: 0},
+	}
+	for _, g := range golden {
+		if got := floorDiv(g.x, g.y); got != g.want {
+			t.Errorf("floorDiv(%d, %d) mismatch; expected %d, got %d", g.x, g.y, g.want, got)
+		}
+	}
+}
+
+// checkSameImage reports an error if the pixels of the lazy view differ from
+// the pixels of the view.
+func checkSameImage(t *testing.T, name string, view *View, lv *LazyView) {
+	b := view.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := color.RGBAModel.Convert(view.At(x, y))
+			if got := lv.At(x, y); got != want {
+				t.Errorf("%s: pixel mismatch at (%d, %d); expected %v, got %v", name, x, y, want, got)
+				return
+			}
+		}
+	}
+}
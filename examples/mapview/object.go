@@ -0,0 +1,94 @@
+package mapview
+
+import (
+	"image"
+	"math"
+
+	"github.com/mewspring/tmx"
+)
+
+// DrawObjects draws the image representation of the tile objects and text
+// objects of the map to the view image. Hidden objects, objects of hidden
+// layers and other kinds of objects are skipped.
+func (view *View) DrawObjects() {
+	for i := range view.objectLayers {
+		ol := &view.objectLayers[i]
+		if !ol.EffectiveVisible() {
+			continue
+		}
+		view.drawObjectLayer(ol)
+	}
+}
+
+// drawObjectLayer draws the image representation of the tile objects and text
+// objects of the given object layer to the view image, in the draw order of the
+// layer and using the effective opacity of the layer.
+func (view *View) drawObjectLayer(ol *tmx.ObjectLayer) {
+	opacity := ol.EffectiveOpacity()
+	for _, o := range ol.DrawOrderedObjects() {
+		if !o.Visible {
+			continue
+		}
+		if o.Text != nil {
+			view.drawText(o, image.Pt(ol.OffsetX, ol.OffsetY), opacity)
+			continue
+		}
+		if o.GID == 0 {
+			continue
+		}
+		// Tile objects may be flipped, just like the tiles of tile layers.
+		tile, ok := view.tileset.TileForRawGID(o.GID)
+		if !ok {
+			continue
+		}
+		sr := tile.Bounds()
+		pt := view.objectPos(o.X, o.Y)
+		// Tile objects are drawn at the tile size of their tileset, regardless
+		// of the object size, so the fill mode of the tileset never applies.
+		//
+		// Tile objects are aligned to the bottom-left in orthogonal orientation
+		// and to the bottom-center in isometric orientation.
+		dr := image.Rect(pt.X, pt.Y-tile.Size.Y, pt.X+tile.Size.X, pt.Y)
+		if view.orientation == "isometric" {
+			dr = dr.Sub(image.Pt(tile.Size.X/2, 0))
+		}
+		dr = dr.Add(tile.Offset)
+		dr = dr.Add(image.Pt(ol.OffsetX, ol.OffsetY))
+		dr = dr.Add(view.origin)
+		view.drawOver(dr, tile, sr.Min, opacity)
+	}
+}
+
+// objectPos returns the position in the view image of the provided object
+// coordinates, which are specified in pixels. Fractional positions are rounded
+// down to whole pixels, and the coordinates may be negative.
+func (view *View) objectPos(x, y float64) image.Point {
+	if view.orientation != "isometric" {
+		return image.Pt(floorInt(x), floorInt(y))
+	}
+	// Isometric object coordinates are projected onto the map grid, where the
+	// tile height in pixels corresponds to one cell along each axis. The top
+	// corner of cell (0, 0) is located at the center of the top edge.
+	tw, th := float64(view.tileWidth), float64(view.tileHeight)
+	sx := float64(view.rows)*tw/2 + (x-y)*tw/(2*th)
+	sy := (x + y) / 2
+	return image.Pt(floorInt(sx), floorInt(sy))
+}
+
+// maxCoord bounds the pixel coordinates of objects, which prevents objects far
+// outside of the map from overflowing the arithmetic of drawing rectangles.
+const maxCoord = 1 << 24
+
+// floorInt returns the greatest integer less than or equal to x, clamped to the
+// range [-maxCoord, maxCoord]. NaN is treated as 0.
+func floorInt(x float64) int {
+	switch {
+	case math.IsNaN(x):
+		return 0
+	case x < -maxCoord:
+		return -maxCoord
+	case x > maxCoord:
+		return maxCoord
+	}
+	return int(math.Floor(x))
+}
@@ -0,0 +1,83 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestViewObjectPos(t *testing.T) {
+	golden := []struct {
+		orientation string
+		x, y        float64
+		want        image.Point
+	}{
+		{orientation: "orthogonal", x: 10, y: 20, want: image.Pt(10, 20)},
+		// Fractional positions are rounded down.
+		{orientation: "orthogonal", x: 1.5, y: -0.5, want: image.Pt(1, -1)},
+		// The top corner of cell (0, 0) is located at the center of the top
+		// edge of the 2x2 map.
+		{orientation: "isometric", x: 0, y: 0, want: image.Pt(32, 0)},
+		{orientation: "isometric", x: 16, y: 0, want: image.Pt(48, 8)},
+		{orientation: "isometric", x: 16.5, y: 0.25, want: image.Pt(48, 8)},
+		{orientation: "isometric", x: 0, y: 16, want: image.Pt(16, 8)},
+	}
+	for _, g := range golden {
+		src := `
+<map version="1.0" orientation="` + g.orientation + `" width="2" height="2" tilewidth="32" tileheight="16">` + testTileset + `
+</map>`
+		view := newTestView(t, src)
+		if got := view.objectPos(g.x, g.y); got != g.want {
+			t.Errorf("%s (%v, %v): position mismatch; expected %v, got %v", g.orientation, g.x, g.y, g.want, got)
+		}
+	}
+}
+
+func TestFloorInt(t *testing.T) {
+	golden := []struct {
+		x    float64
+		want int
+	}{
+		{x: 0, want: 0},
+		{x: 1.5, want: 1},
+		{x: -0.5, want: -1},
+		{x: -16, want: -16},
+		{x: maxCoord, want: maxCoord},
+		{x: maxCoord + 1, want: maxCoord},
+		{x: -maxCoord - 1, want: -maxCoord},
+		{x: 1e300, want: maxCoord},
+		{x: -1e300, want: -maxCoord},
+		{x: math.Inf(1), want: maxCoord},
+		{x: math.Inf(-1), want: -maxCoord},
+		{x: math.NaN(), want: 0},
+	}
+	for _, g := range golden {
+		if got := floorInt(g.x); got != g.want {
+			t.Errorf("floorInt(%v) mismatch; expected %d, got %d", g.x, g.want, got)
+		}
+	}
+}
+
+func TestViewDrawObjectsOutside(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <objectgroup name="objects">
+  <object id="1" gid="1" x="-8" y="8" width="16" height="16"/>
+  <object id="2" gid="2" x="1e30" y="1e30" width="16" height="16"/>
+  <object id="3" gid="3" x="-1e30" y="-1e30" width="16" height="16"/>
+  <object id="4" x="-1e30" y="0" width="1e31" height="16"/>
+ </objectgroup>
+</map>`
+	view := newTestView(t, src)
+	view.DrawObjects()
+	// The object at a negative position is clipped to the view image, and
+	// objects far outside of the map are skipped.
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), red},
+		{image.Pt(7, 7), red},
+		{image.Pt(8, 7), color.RGBA{}},
+		{image.Pt(0, 8), color.RGBA{}},
+		{image.Pt(31, 31), color.RGBA{}},
+	})
+}
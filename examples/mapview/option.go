@@ -0,0 +1,32 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// An Option configures a view of a map.
+type Option func(*View)
+
+// WithCheckerboard fills the view image with a checkerboard of size by size
+// pixel squares, alternating between the colors c1 and c2, before anything is
+// drawn. The checkerboard visualizes transparent regions of the map.
+func WithCheckerboard(size int, c1, c2 color.Color) Option {
+	return func(view *View) {
+		if size <= 0 {
+			return
+		}
+		src1, src2 := image.NewUniform(c1), image.NewUniform(c2)
+		b := view.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y += size {
+			for x := b.Min.X; x < b.Max.X; x += size {
+				src := src1
+				if ((x-b.Min.X)/size+(y-b.Min.Y)/size)%2 == 1 {
+					src = src2
+				}
+				draw.Draw(view, image.Rect(x, y, x+size, y+size), src, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
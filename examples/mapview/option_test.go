@@ -0,0 +1,38 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWithCheckerboard(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">0,3</data>
+ </layer>
+</map>`
+	gray := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+	view := newTestView(t, src, WithCheckerboard(4, white, gray))
+	// The checkerboard is filled before anything is drawn.
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), white},
+		{image.Pt(3, 3), white},
+		{image.Pt(4, 0), gray},
+		{image.Pt(0, 4), gray},
+		{image.Pt(4, 4), white},
+		{image.Pt(31, 15), white},
+		{image.Pt(24, 15), gray},
+	})
+	view.Draw()
+	// Transparent regions of the map show the checkerboard.
+	checkPixels(t, view, []pixel{
+		{image.Pt(4, 0), gray},
+		{image.Pt(16, 0), blue},
+		{image.Pt(31, 15), blue},
+	})
+	// A non-positive size leaves the view image transparent.
+	view = newTestView(t, src, WithCheckerboard(0, white, gray))
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), color.RGBA{}}})
+}
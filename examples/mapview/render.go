@@ -0,0 +1,23 @@
+package mapview
+
+import (
+	"path/filepath"
+
+	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewspring/tmx"
+)
+
+// RenderToFile reads the provided tmx file, draws the image representation of
+// the map and writes it to the provided png file.
+func RenderToFile(tmxPath, pngPath string) error {
+	m, err := tmx.Open(tmxPath)
+	if err != nil {
+		return err
+	}
+	view, err := NewView(m, filepath.Dir(tmxPath))
+	if err != nil {
+		return err
+	}
+	view.Draw()
+	return imgutil.WriteFile(pngPath, view)
+}
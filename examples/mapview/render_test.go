@@ -0,0 +1,50 @@
+package mapview
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderToFile(t *testing.T) {
+	dir := t.TempDir()
+	writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red, green, blue)
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">1,3</data>
+ </layer>
+</map>`
+	tmxPath := filepath.Join(dir, "map.tmx")
+	if err := ioutil.WriteFile(tmxPath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pngPath := filepath.Join(dir, "map.png")
+	if err := RenderToFile(tmxPath, pngPath); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := img.Bounds().Size(), image.Pt(32, 16); got != want {
+		t.Fatalf("image size mismatch; expected %v, got %v", want, got)
+	}
+	checkPixels(t, img, []pixel{{image.Pt(0, 0), red}, {image.Pt(16, 0), blue}})
+	// Missing tmx files are reported, and no png file is written.
+	missing := filepath.Join(dir, "missing.png")
+	if err := RenderToFile(filepath.Join(dir, "missing.tmx"), missing); err == nil {
+		t.Error("expected error for missing tmx file")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("expected no png file for missing tmx file; %v", err)
+	}
+}
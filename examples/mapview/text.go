@@ -0,0 +1,78 @@
+package mapview
+
+import (
+	"image"
+	"strings"
+
+	"github.com/mewspring/tmx"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// drawText draws the text of the given text object to the view image, offset by
+// the provided number of pixels and using the given opacity. The text is
+// rendered using a basic bitmap font which is scaled to the pixel size of the
+// text.
+func (view *View) drawText(o *tmx.Object, offset image.Point, opacity float64) {
+	t := o.Text
+	c, err := tmx.ParseColor(t.Color)
+	if err != nil {
+		c = tmx.Color{A: 0xFF}
+	}
+	face := basicfont.Face7x13
+	factor := float64(t.PixelSize) / float64(face.Height)
+	if factor <= 0 {
+		return
+	}
+	var lines []string
+	for _, line := range strings.Split(t.Text, "\n") {
+		if t.Wrap && o.Width > 0 {
+			maxChars := floorInt(o.Width / factor / float64(face.Advance))
+			lines = append(lines, wrap(line, maxChars)...)
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	// Render the text at the native size of the font.
+	var width int
+	for _, line := range lines {
+		width = max(width, len([]rune(line))*face.Advance)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, len(lines)*face.Height))
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+	}
+	for i, line := range lines {
+		d.Dot = fixed.P(0, i*face.Height+face.Ascent)
+		d.DrawString(line)
+	}
+
+	// Draw the scaled text.
+	text := scale(img, factor)
+	pt := view.objectPos(o.X, o.Y).Add(offset).Add(view.origin)
+	dr := text.Bounds().Add(pt)
+	view.drawOver(dr, text, image.Point{}, opacity)
+}
+
+// wrap splits the line at word boundaries into lines of at most maxChars
+// characters. Words longer than maxChars are kept on a line of their own.
+func wrap(line string, maxChars int) []string {
+	var lines []string
+	var cur string
+	for _, word := range strings.Fields(line) {
+		switch {
+		case cur == "":
+			cur = word
+		case len([]rune(cur))+1+len([]rune(word)) <= maxChars:
+			cur += " " + word
+		default:
+			lines = append(lines, cur)
+			cur = word
+		}
+	}
+	return append(lines, cur)
+}
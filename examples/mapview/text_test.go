@@ -0,0 +1,71 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawText(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="8" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <objectgroup name="labels" offsetx="4">
+  <object id="1" x="16" y="8" width="96" height="13">
+   <text pixelsize="13" color="#00ff00">Hi</text>
+  </object>
+ </objectgroup>
+</map>`
+	view := newTestView(t, src)
+	view.DrawObjects()
+	// The glyphs of the text are drawn in the color of the text, within the
+	// rectangle starting at the position of the object.
+	textRect := image.Rect(20, 8, 20+2*7, 8+13)
+	found := false
+	b := view.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(view.At(x, y))
+			if c == (color.RGBA{}) {
+				continue
+			}
+			if !image.Pt(x, y).In(textRect) {
+				t.Fatalf("pixel at (%d, %d) outside of text rectangle %v", x, y, textRect)
+			}
+			if c != green {
+				t.Fatalf("pixel mismatch at (%d, %d); expected %v, got %v", x, y, green, c)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected text to be drawn")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	golden := []struct {
+		line     string
+		maxChars int
+		want     []string
+	}{
+		{line: "hello world", maxChars: 11, want: []string{"hello world"}},
+		{line: "hello world", maxChars: 10, want: []string{"hello", "world"}},
+		{line: "a b c d", maxChars: 3, want: []string{"a b", "c d"}},
+		// Words longer than maxChars are kept on a line of their own.
+		{line: "a verylongword b", maxChars: 4, want: []string{"a", "verylongword", "b"}},
+		{line: "", maxChars: 4, want: []string{""}},
+	}
+	for _, g := range golden {
+		got := wrap(g.line, g.maxChars)
+		if len(got) != len(g.want) {
+			t.Errorf("%q: lines mismatch; expected %q, got %q", g.line, g.want, got)
+			continue
+		}
+		for i := range g.want {
+			if got[i] != g.want[i] {
+				t.Errorf("%q: lines mismatch; expected %q, got %q", g.line, g.want, got)
+				break
+			}
+		}
+	}
+}
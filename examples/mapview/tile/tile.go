@@ -3,8 +3,11 @@ package tile
 
 import (
 	"image"
+	"image/color"
+	"sort"
 
 	"github.com/mewkiz/pkg/imgutil"
+	"github.com/mewspring/tmx"
 )
 
 // Tileset is a map from a tile ID to a tile image.
@@ -15,6 +18,9 @@ type Tile struct {
 	image.Image
 	// Offset to be applied when drawing the tile.
 	Offset image.Point
+	// Size is the tile size of the tileset which contains the tile. The tile
+	// image may be smaller, e.g. at the edges of the sprite sheet.
+	Size image.Point
 }
 
 // NewTileset returns a new tileset.
@@ -23,17 +29,37 @@ func NewTileset() (tileset Tileset) {
 	return tileset
 }
 
+// Len returns the number of tiles in the tileset.
+func (tileset Tileset) Len() int {
+	return len(tileset)
+}
+
+// IDs returns the tile IDs of the tileset in ascending order.
+func (tileset Tileset) IDs() []int {
+	ids := make([]int, 0, len(tileset))
+	for id := range tileset {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
 /// ### [ todo ] ###
 ///   - handle Margin?
 ///   - handle Spacing?
 /// ### [/ todo ] ###
 
 // AddTiles adds tiles to the tileset based on a provided sprite sheet, using
-// startID as the first tile id.
+// startID as the first tile id. It returns the number of added tiles. Pixels of
+// the sprite sheet with the transparent color trans are made transparent, unless
+// trans is nil.
 //
 // Note: If possible the added tiles will share pixels with the provided sprite
-// sheet.
-func (tileset Tileset) AddTiles(spriteSheet image.Image, startID, tileWidth, tileHeight int, tileOffset image.Point) {
+// sheet. This is not possible when a transparent color is used.
+func (tileset Tileset) AddTiles(spriteSheet image.Image, startID, tileWidth, tileHeight int, tileOffset image.Point, trans color.Color) (n int) {
+	if trans != nil {
+		spriteSheet = keyColor(spriteSheet, trans)
+	}
 	sub := imgutil.SubFallback(spriteSheet)
 	r := sub.Bounds()
 	id := startID
@@ -43,9 +69,68 @@ func (tileset Tileset) AddTiles(spriteSheet image.Image, startID, tileWidth, til
 			tile := Tile{
 				Image:  sub.SubImage(tileRect),
 				Offset: tileOffset,
+				Size:   image.Pt(tileWidth, tileHeight),
 			}
 			tileset[id] = tile
 			id++
 		}
 	}
+	return id - startID
+}
+
+// TileForRawGID returns the tile of the provided raw global tile ID. The tile
+// image is flipped according to the flip flags of the GID.
+func (tileset Tileset) TileForRawGID(gid tmx.GID) (tile Tile, ok bool) {
+	tile, ok = tileset[gid.GlobalTileID()]
+	if !ok || !gid.IsFlip() {
+		return tile, ok
+	}
+	tile.Image = flip(tile.Image, gid.IsHorizontalFlip(), gid.IsVerticalFlip(), gid.IsDiagonalFlip())
+	return tile, true
+}
+
+// flip returns a flipped copy of the provided image. The diagonal flip is
+// applied first, followed by the horizontal and vertical flips.
+func flip(img image.Image, h, v, d bool) image.Image {
+	sr := img.Bounds()
+	width, height := sr.Dx(), sr.Dy()
+	if d {
+		width, height = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Locate the source pixel by undoing the flips in reverse order.
+			sx, sy := x, y
+			if h {
+				sx = width - 1 - sx
+			}
+			if v {
+				sy = height - 1 - sy
+			}
+			if d {
+				sx, sy = sy, sx
+			}
+			dst.Set(x, y, img.At(sr.Min.X+sx, sr.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// keyColor returns a copy of the provided image, in which the pixels with the
+// transparent color trans have been made transparent.
+func keyColor(img image.Image, trans color.Color) image.Image {
+	tr, tg, tb, _ := trans.RGBA()
+	sr := img.Bounds()
+	dst := image.NewNRGBA(sr)
+	for y := sr.Min.Y; y < sr.Max.Y; y++ {
+		for x := sr.Min.X; x < sr.Max.X; x++ {
+			c := img.At(x, y)
+			if r, g, b, a := c.RGBA(); a == 0xFFFF && r == tr && g == tg && b == tb {
+				continue
+			}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
 }
@@ -0,0 +1,169 @@
+package tile
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/mewspring/tmx"
+)
+
+// Colors of the pixels of the test tile.
+var (
+	red   = color.RGBA{R: 0xFF, A: 0xFF}
+	green = color.RGBA{G: 0xFF, A: 0xFF}
+	blue  = color.RGBA{B: 0xFF, A: 0xFF}
+	white = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+)
+
+// newTestTileset returns a tileset containing a single 2x2 tile with GID 1,
+// whose pixels are red and green in the first row and blue and white in the
+// second row.
+func newTestTileset() Tileset {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, red)
+	img.Set(1, 0, green)
+	img.Set(0, 1, blue)
+	img.Set(1, 1, white)
+	return Tileset{1: Tile{Image: img, Offset: image.Pt(1, 2), Size: image.Pt(2, 2)}}
+}
+
+func TestTileForRawGID(t *testing.T) {
+	golden := []struct {
+		h, v, d bool
+		// Expected pixels in row-major order.
+		want []color.RGBA
+	}{
+		{want: []color.RGBA{red, green, blue, white}},
+		{h: true, want: []color.RGBA{green, red, white, blue}},
+		{v: true, want: []color.RGBA{blue, white, red, green}},
+		{h: true, v: true, want: []color.RGBA{white, blue, green, red}},
+		{d: true, want: []color.RGBA{red, blue, green, white}},
+		// Rotated 90 degrees clockwise.
+		{h: true, d: true, want: []color.RGBA{blue, red, white, green}},
+	}
+	tileset := newTestTileset()
+	for _, g := range golden {
+		gid := tmx.MakeGID(1, g.h, g.v, g.d)
+		tile, ok := tileset.TileForRawGID(gid)
+		if !ok {
+			t.Errorf("h=%v, v=%v, d=%v: unable to locate tile", g.h, g.v, g.d)
+			continue
+		}
+		if got, want := tile.Offset, image.Pt(1, 2); got != want {
+			t.Errorf("h=%v, v=%v, d=%v: offset mismatch; expected %v, got %v", g.h, g.v, g.d, want, got)
+		}
+		b := tile.Bounds()
+		for i, want := range g.want {
+			x, y := b.Min.X+i%2, b.Min.Y+i/2
+			got := color.RGBAModel.Convert(tile.At(x, y))
+			if got != want {
+				t.Errorf("h=%v, v=%v, d=%v: pixel mismatch at (%d, %d); expected %v, got %v", g.h, g.v, g.d, x, y, want, got)
+			}
+		}
+	}
+}
+
+func TestTileForRawGIDDiagonal(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	tileset := Tileset{1: Tile{Image: img}}
+	tile, ok := tileset.TileForRawGID(tmx.MakeGID(1, false, false, true))
+	if !ok {
+		t.Fatal("unable to locate tile")
+	}
+	// The width and height are swapped by diagonal flips.
+	if got, want := tile.Bounds().Size(), image.Pt(1, 3); got != want {
+		t.Errorf("tile size mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestTileForRawGIDMissing(t *testing.T) {
+	tileset := newTestTileset()
+	for _, gid := range []tmx.GID{0, 2, tmx.MakeGID(2, true, false, false)} {
+		if _, ok := tileset.TileForRawGID(gid); ok {
+			t.Errorf("gid=%d: expected missing tile", gid)
+		}
+	}
+}
+
+func TestTilesetIDs(t *testing.T) {
+	tileset := NewTileset()
+	if got := tileset.Len(); got != 0 {
+		t.Errorf("number of tiles mismatch; expected 0, got %d", got)
+	}
+	// Two sprite sheets of 2x2 and 1x1 tiles respectively.
+	tileset.AddTiles(image.NewRGBA(image.Rect(0, 0, 4, 4)), 10, 2, 2, image.Point{}, nil)
+	tileset.AddTiles(image.NewRGBA(image.Rect(0, 0, 2, 2)), 1, 2, 2, image.Point{}, nil)
+	if got, want := tileset.Len(), 5; got != want {
+		t.Errorf("number of tiles mismatch; expected %d, got %d", want, got)
+	}
+	want := []int{1, 10, 11, 12, 13}
+	got := tileset.IDs()
+	if len(got) != len(want) {
+		t.Fatalf("IDs mismatch; expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("IDs mismatch; expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestAddTilesTrans(t *testing.T) {
+	// A sprite sheet of two 2x2 tiles; the first tile contains a red, a green,
+	// a blue and a semi-transparent red pixel, and the second tile is red.
+	sheet := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	sheet.Set(0, 0, red)
+	sheet.Set(1, 0, green)
+	sheet.Set(0, 1, blue)
+	sheet.Set(1, 1, color.NRGBA{R: 0xFF, A: 0x80})
+	for y := 0; y < 2; y++ {
+		for x := 2; x < 4; x++ {
+			sheet.Set(x, y, red)
+		}
+	}
+	transparent := color.NRGBA{}
+	golden := []struct {
+		trans color.Color
+		// Expected pixels of the tiles in row-major order.
+		want [2][]color.NRGBA
+	}{
+		{
+			trans: nil,
+			want: [2][]color.NRGBA{
+				{{R: 0xFF, A: 0xFF}, {G: 0xFF, A: 0xFF}, {B: 0xFF, A: 0xFF}, {R: 0xFF, A: 0x80}},
+				{{R: 0xFF, A: 0xFF}, {R: 0xFF, A: 0xFF}, {R: 0xFF, A: 0xFF}, {R: 0xFF, A: 0xFF}},
+			},
+		},
+		// Only opaque pixels of the transparent color are made transparent.
+		{
+			trans: tmx.Color{R: 0xFF, A: 0xFF},
+			want: [2][]color.NRGBA{
+				{transparent, {G: 0xFF, A: 0xFF}, {B: 0xFF, A: 0xFF}, {R: 0xFF, A: 0x80}},
+				{transparent, transparent, transparent, transparent},
+			},
+		},
+	}
+	for _, g := range golden {
+		tileset := make(Tileset)
+		if n := tileset.AddTiles(sheet, 1, 2, 2, image.Point{}, g.trans); n != 2 {
+			t.Errorf("trans=%v: number of tiles mismatch; expected 2, got %d", g.trans, n)
+			continue
+		}
+		for i, want := range g.want {
+			tile := tileset[i+1]
+			b := tile.Image.Bounds()
+			for j, c := range want {
+				x, y := b.Min.X+j%2, b.Min.Y+j/2
+				got := color.NRGBAModel.Convert(tile.Image.At(x, y))
+				if got != c {
+					t.Errorf("trans=%v: pixel mismatch of tile %d at (%d, %d); expected %v, got %v", g.trans, i+1, x, y, c, got)
+				}
+			}
+		}
+	}
+	// The sprite sheet is left unchanged.
+	if got := color.NRGBAModel.Convert(sheet.At(2, 0)); got != color.NRGBAModel.Convert(red) {
+		t.Errorf("sprite sheet pixel mismatch; expected %v, got %v", red, got)
+	}
+}
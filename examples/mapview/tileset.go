@@ -1,24 +1,117 @@
 package mapview
 
-import "image"
-
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"path/filepath"
+
 	"github.com/mewkiz/pkg/imgutil"
 	"github.com/mewspring/tmx"
 	"github.com/mewspring/tmx/examples/mapview/tile"
 )
 
+// OpenResolved reads the provided tmx file, including its external tilesets,
+// and loads the tileset images. The returned map and tileset are ready to be
+// rendered.
+//
+// Note: Object templates are not yet supported.
+func OpenResolved(tmxPath string, opts ...tmx.Option) (*tmx.Map, tile.Tileset, error) {
+	m, err := tmx.Open(tmxPath, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	tileset, err := GetTileset(m, filepath.Dir(tmxPath))
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, tileset, nil
+}
+
 // GetTileset returns the combined tileset of a given tmx map.
 func GetTileset(m *tmx.Map, dir string) (tileset tile.Tileset, err error) {
 	tileset = tile.NewTileset()
+	maxIDs := maxLocalIDs(m)
 	for _, ts := range m.Tilesets {
-		spritePath := dir + "/" + ts.Image.Source
-		spriteSheet, err := imgutil.ReadFile(spritePath)
+		spriteSheet, err := readImage(ts.Image, dir)
 		if err != nil {
 			return nil, err
 		}
 		tileOffset := image.Pt(ts.TileOffset.X, ts.TileOffset.Y)
-		tileset.AddTiles(spriteSheet, ts.FirstGID, ts.TileWidth, ts.TileHeight, tileOffset)
+		var trans color.Color
+		if ts.Image.Trans != "" {
+			trans, err = tmx.ParseColor(ts.Image.Trans)
+			if err != nil {
+				return nil, err
+			}
+		}
+		n := tileset.AddTiles(spriteSheet, ts.FirstGID, ts.TileWidth, ts.TileHeight, tileOffset, trans)
+		// Catch sprite sheets which are too small for the tileset.
+		if maxID, ok := maxIDs[ts.FirstGID]; ok && maxID >= n {
+			return nil, fmt.Errorf("GetTileset: the image of tileset '%s' contains %d tiles, but the map uses local tile ID %d.", ts.Name, n, maxID)
+		}
 	}
 	return tileset, nil
 }
+
+// maxLocalIDs returns the highest local tile ID referenced by the tile layers
+// and tile objects of the map, for each tileset identified by its first GID.
+func maxLocalIDs(m *tmx.Map) map[int]int {
+	maxIDs := make(map[int]int)
+	mark := func(gid int) {
+		ts := m.TilesetForGID(gid)
+		if ts == nil {
+			return
+		}
+		id := gid - ts.FirstGID
+		if maxID, ok := maxIDs[ts.FirstGID]; !ok || id > maxID {
+			maxIDs[ts.FirstGID] = id
+		}
+	}
+	for i := range m.Layers {
+		layer := &m.Layers[i]
+		if m.Infinite {
+			for _, c := range layer.Data.Chunks {
+				for row := c.Y; row < c.Y+c.Height; row++ {
+					for col := c.X; col < c.X+c.Width; col++ {
+						mark(layer.GetGID(col, row))
+					}
+				}
+			}
+			continue
+		}
+		for row := 0; row < m.Height; row++ {
+			for col := 0; col < m.Width; col++ {
+				mark(layer.GetGID(col, row))
+			}
+		}
+	}
+	for _, ol := range m.ObjectLayers {
+		for _, o := range ol.Objects {
+			mark(o.GID.GlobalTileID())
+		}
+	}
+	return maxIDs
+}
+
+// readImage reads the provided tileset image, either from the image file
+// located relative to dir or from the embedded image data.
+func readImage(img tmx.Image, dir string) (image.Image, error) {
+	if img.Source != "" {
+		return imgutil.ReadFile(dir + "/" + img.Source)
+	}
+	if img.Data == nil {
+		return nil, fmt.Errorf("readImage: image has neither source nor embedded data.")
+	}
+	buf, err := img.Data.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	spriteSheet, _, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	return spriteSheet, nil
+}
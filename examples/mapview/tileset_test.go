@@ -0,0 +1,73 @@
+package mapview
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenResolved(t *testing.T) {
+	// The map, the external tileset and its image are located in different
+	// directories.
+	dir := t.TempDir()
+	for _, name := range []string{"maps", "tilesets", "images"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeSheet(t, filepath.Join(dir, "images", "tiles.png"), 16, red, green, blue)
+	files := map[string]string{
+		"maps/level.tmx": `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" source="../tilesets/tiles.tsx"/>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">3,2</data>
+ </layer>
+</map>`,
+		"tilesets/tiles.tsx": `
+<tileset name="tiles" tilewidth="16" tileheight="16" tilecount="3" columns="3">
+ <image source="../images/tiles.png" width="48" height="16"/>
+</tileset>`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, tileset, err := OpenResolved(filepath.Join(dir, "maps", "level.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tilesets) != 1 || m.Tilesets[0].Name != "tiles" {
+		t.Fatalf("expected external tileset to be resolved, got %d tilesets", len(m.Tilesets))
+	}
+	golden := []struct {
+		gid  int
+		want color.RGBA
+	}{
+		{gid: 1, want: red},
+		{gid: 2, want: green},
+		{gid: 3, want: blue},
+	}
+	for _, g := range golden {
+		tile, ok := tileset[g.gid]
+		if !ok {
+			t.Errorf("GID %d: unable to locate tile", g.gid)
+			continue
+		}
+		checkPixels(t, tile.Image, []pixel{{tile.Image.Bounds().Min.Add(image.Pt(8, 8)), g.want}})
+	}
+	// Missing maps and tileset images are reported.
+	if _, _, err := OpenResolved(filepath.Join(dir, "maps", "missing.tmx")); err == nil {
+		t.Error("expected error for missing map")
+	}
+	if err := os.Remove(filepath.Join(dir, "images", "tiles.png")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenResolved(filepath.Join(dir, "maps", "level.tmx")); err == nil {
+		t.Error("expected error for missing tileset image")
+	}
+}
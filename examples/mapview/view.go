@@ -3,7 +3,9 @@
 package mapview
 
 import (
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 
 	"github.com/mewspring/tmx"
@@ -25,45 +27,108 @@ type View struct {
 	// delta is the differance between the map's standard tile height and the
 	// maximum tile height of all tilesets.
 	delta int
+	// origin is the position in the view image of the top-left corner of the
+	// map. The map is shifted down by delta pixels to make room for tall
+	// tiles, and further down and to the right to make room for tiles which
+	// are shifted past the top or left edge of the map by their tile offset.
+	origin image.Point
 	// layers associated with the map.
 	layers []tmx.Layer
+	// objectLayers associated with the map.
+	objectLayers []tmx.ObjectLayer
 	// tileset is a map from a tile ID to a tile image.
 	tileset tile.Tileset
-	// isOrtho is true if the map is orthogonal and false if the map is
-	// isometric.
-	isOrtho bool
+	// orientation corresponds to the map orientation.
+	orientation string
+	// staggerX is true if the x-axis of a staggered map is staggered, and false
+	// if the y-axis is staggered.
+	staggerX bool
+	// staggerEven is true if the even indices along the staggered axis are
+	// shifted, and false if the odd indices are shifted.
+	staggerEven bool
 }
 
-// NewView returns a new view of the map. The tileset sprite sheet is loaded
-// relative to the tmx dir.
-func NewView(m *tmx.Map, dir string) (view *View, err error) {
+// NewView returns a new view of the map, configured by the provided options.
+// The tileset sprite sheet is loaded relative to the tmx dir.
+func NewView(m *tmx.Map, dir string, opts ...Option) (view *View, err error) {
+	view, width, height, err := newView(m, dir)
+	if err != nil {
+		return nil, err
+	}
+	view.Image = image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, opt := range opts {
+		opt(view)
+	}
+	return view, nil
+}
+
+// newView returns a new view of the map without a view image, and the
+// dimensions of the view image. The tileset sprite sheet is loaded relative to
+// the tmx dir.
+func newView(m *tmx.Map, dir string) (view *View, width, height int, err error) {
 	view = &View{
-		cols:       m.Width,
-		rows:       m.Height,
-		tileWidth:  m.TileWidth,
-		tileHeight: m.TileHeight,
-		delta:      getDelta(m),
-		layers:     m.Layers,
-	}
-	if m.Orientation == "orthogonal" {
-		view.isOrtho = true
-	}
-	var width, height int
-	if view.isOrtho {
+		cols:         m.Width,
+		rows:         m.Height,
+		tileWidth:    m.TileWidth,
+		tileHeight:   m.TileHeight,
+		delta:        getDelta(m),
+		layers:       m.Layers,
+		objectLayers: m.ObjectLayers,
+		orientation:  m.Orientation,
+		staggerX:     m.StaggerAxis == "x",
+		staggerEven:  m.StaggerIndex == "even",
+	}
+	switch view.orientation {
+	case "orthogonal":
+		// Tall tiles extend above the first row by delta pixels.
 		width = view.cols * view.tileWidth
 		height = view.rows*view.tileHeight + view.delta
-	} else {
+	case "staggered":
+		// Every other row (or column) is shifted by half a tile.
+		if view.staggerX {
+			width = (view.cols + 1) * view.tileWidth / 2
+			height = view.rows*view.tileHeight + view.tileHeight/2 + view.delta
+		} else {
+			width = view.cols*view.tileWidth + view.tileWidth/2
+			height = (view.rows+1)*view.tileHeight/2 + view.delta
+		}
+	case "isometric":
 		// Each map is (cols+rows)/2 number of tiles in width and height.
 		i := (view.cols + view.rows) / 2
 		width = i * view.tileWidth
 		height = i*view.tileHeight + view.delta
+	default:
+		return nil, 0, 0, fmt.Errorf("NewView: orientation '%s' not yet supported.", view.orientation)
 	}
-	view.Image = image.NewRGBA(image.Rect(0, 0, width, height))
 	view.tileset, err = GetTileset(m, dir)
 	if err != nil {
-		return nil, err
+		return nil, 0, 0, err
 	}
-	return view, nil
+	// Extend the image to make room for tiles which are shifted past the edges
+	// of the map by their tile offset.
+	bounds := view.contentBounds(image.Rect(0, -view.delta, width, height-view.delta))
+	view.origin = image.Pt(-bounds.Min.X, -bounds.Min.Y)
+	return view, bounds.Dx(), bounds.Dy(), nil
+}
+
+// contentBounds returns the union of the provided rectangle and the rectangles
+// in which the tiles of the tile layers are drawn, relative to the top-left
+// corner of the map.
+func (view *View) contentBounds(bounds image.Rectangle) image.Rectangle {
+	for i := range view.layers {
+		layer := &view.layers[i]
+		for row := 0; row < view.rows; row++ {
+			for col := 0; col < view.cols; col++ {
+				t, ok := view.tileset[layer.GetGID(col, row)]
+				if !ok {
+					continue
+				}
+				r := view.GetTileRect(col, row, image.Rectangle{Max: t.Size})
+				bounds = bounds.Union(r.Add(t.Offset))
+			}
+		}
+	}
+	return bounds
 }
 
 // getDelta returns the differance between the map's standard tile height and
@@ -104,18 +169,42 @@ func getDelta(m *tmx.Map) int {
 //             +---------------+
 //
 //                 (5, 8)
+//
+// The coordinates may be outside of the map, in which case the rectangle may
+// have negative coordinates.
 func (view *View) GetCellRect(col, row int) image.Rectangle {
 	halfTileWidth := view.tileWidth / 2
 	halfTileHeight := view.tileHeight / 2
 
 	var x, y int
-	if view.isOrtho {
+	switch view.orientation {
+	case "orthogonal":
 		// X offset to cell:
-		x = (col - 1) * view.tileWidth
+		x = col * view.tileWidth
 
-		// X offset to cell:
-		y = (row - 1) * view.tileHeight
-	} else {
+		// Y offset to cell:
+		y = row * view.tileHeight
+	case "staggered":
+		if view.staggerX {
+			// X offset to cell:
+			x = col * halfTileWidth
+
+			// Y offset to cell, adjusted for shifted columns:
+			y = row * view.tileHeight
+			if view.isStaggered(col) {
+				y += halfTileHeight
+			}
+		} else {
+			// X offset to cell, adjusted for shifted rows:
+			x = col * view.tileWidth
+			if view.isStaggered(row) {
+				x += halfTileWidth
+			}
+
+			// Y offset to cell:
+			y = row * halfTileHeight
+		}
+	default:
 		// X offset to cell (0, 0):
 		x = (view.rows - 1) * halfTileWidth
 		// Adjust x offset based on col:
@@ -134,6 +223,15 @@ func (view *View) GetCellRect(col, row int) image.Rectangle {
 	return image.Rect(x, y, x+view.tileWidth, y+view.tileHeight)
 }
 
+// isStaggered returns true if the given index along the staggered axis of a
+// staggered map is shifted by half a tile. The index may be negative.
+func (view *View) isStaggered(i int) bool {
+	if view.staggerEven {
+		return i%2 == 0
+	}
+	return i%2 != 0
+}
+
 // GetTileRect returns the image.Rectangle of the tile at the provided
 // coordinates.
 func (view *View) GetTileRect(col, row int, tileBounds image.Rectangle) image.Rectangle {
@@ -145,25 +243,216 @@ func (view *View) GetTileRect(col, row int, tileBounds image.Rectangle) image.Re
 	return rect
 }
 
-// Draw draws the image representation of the map to the view image.
+// Draw draws the image representation of the map to the view image. Tile
+// layers and object layers are drawn in document order. Hidden layers and
+// layers of hidden group layers are skipped, and the opacity of layers is
+// multiplied by the opacity of the group layers which contain them.
 func (view *View) Draw() {
-	for _, layer := range view.layers {
-		if layer.Name == "collision" {
+	i, j := 0, 0
+	for i < len(view.layers) || j < len(view.objectLayers) {
+		if j >= len(view.objectLayers) || i < len(view.layers) && view.layers[i].Index < view.objectLayers[j].Index {
+			layer := &view.layers[i]
+			i++
+			if layer.Name == "collision" || !layer.EffectiveVisible() {
+				continue
+			}
+			view.drawLayer(layer)
+		} else {
+			ol := &view.objectLayers[j]
+			j++
+			if !ol.EffectiveVisible() {
+				continue
+			}
+			view.drawObjectLayer(ol)
+		}
+	}
+}
+
+// DrawLayer draws the image representation of the named layer to the view
+// image.
+func (view *View) DrawLayer(name string) error {
+	return view.DrawLayers([]string{name})
+}
+
+// DrawLayers draws the image representations of the named layers to the view
+// image, in the given order.
+func (view *View) DrawLayers(names []string) error {
+	var layers []*tmx.Layer
+	for _, name := range names {
+		layer := view.layer(name)
+		if layer == nil {
+			return fmt.Errorf("DrawLayers: unable to locate layer '%s'.", name)
+		}
+		layers = append(layers, layer)
+	}
+	for _, layer := range layers {
+		view.drawLayer(layer)
+	}
+	return nil
+}
+
+// layer returns the named layer, or nil if no such layer exists.
+func (view *View) layer(name string) *tmx.Layer {
+	for i := range view.layers {
+		if view.layers[i].Name == name {
+			return &view.layers[i]
+		}
+	}
+	return nil
+}
+
+// DrawRegion draws the image representation of the tile layers of the map to
+// the view image, restricted to the inclusive range of cells from (minCol,
+// minRow) to (maxCol, maxRow). The range is clamped to the bounds of the map.
+// Tiles outside of the range are left untouched, which makes it possible to
+// redraw only the parts of the map which have changed.
+//
+// Note: Object layers are not drawn.
+func (view *View) DrawRegion(minCol, minRow, maxCol, maxRow int) {
+	minCol, minRow = max(minCol, 0), max(minRow, 0)
+	maxCol, maxRow = min(maxCol, view.cols-1), min(maxRow, view.rows-1)
+	for i := range view.layers {
+		layer := &view.layers[i]
+		if layer.Name == "collision" || !layer.EffectiveVisible() {
+			continue
+		}
+		view.drawLayerRegion(layer, minCol, minRow, maxCol, maxRow)
+	}
+}
+
+// drawLayer draws the image representation of the given layer to the view
+// image.
+func (view *View) drawLayer(layer *tmx.Layer) {
+	view.drawLayerRegion(layer, 0, 0, view.cols-1, view.rows-1)
+}
+
+// drawLayerRegion draws the image representation of the given layer to the
+// view image, restricted to the inclusive range of cells from (minCol, minRow)
+// to (maxCol, maxRow). The tiles are drawn using the effective opacity of the
+// layer.
+func (view *View) drawLayerRegion(layer *tmx.Layer, minCol, minRow, maxCol, maxRow int) {
+	opacity := layer.EffectiveOpacity()
+	for row := minRow; row <= maxRow; row++ {
+		for col := minCol; col <= maxCol; col++ {
+			gid := layer.GetGID(col, row)
+			tile, ok := view.tileset[gid]
+			if !ok {
+				continue
+			}
+			sr := tile.Bounds()
+			view.drawOver(view.drawRect(col, row, tile), tile, sr.Min, opacity)
+		}
+	}
+}
+
+// DrawFunc invokes fn for each non-empty tile of the tile layers of the map,
+// instead of drawing the tile. The layers are visited in document order, and
+// the tiles of each layer in row-major order. The rectangle dst is the
+// rectangle of the view image in which the tile would have been drawn. Like
+// Draw, DrawFunc skips the "collision" layer and hidden layers.
+func (view *View) DrawFunc(fn func(col, row, gid int, dst image.Rectangle, tile tile.Tile)) {
+	for i := range view.layers {
+		layer := &view.layers[i]
+		if layer.Name == "collision" || !layer.EffectiveVisible() {
 			continue
 		}
 		for row := 0; row < view.rows; row++ {
 			for col := 0; col < view.cols; col++ {
 				gid := layer.GetGID(col, row)
-				tile, ok := view.tileset[gid]
+				t, ok := view.tileset[gid]
 				if !ok {
 					continue
 				}
-				sr := tile.Bounds()
-				tileRect := view.GetTileRect(col, row, sr)
-				tileRect = tileRect.Add(tile.Offset)
-				tileRect = tileRect.Add(image.Pt(0, view.delta))
-				draw.Draw(view, tileRect, tile, sr.Min, draw.Over)
+				fn(col, row, gid, view.drawRect(col, row, t), t)
+			}
+		}
+	}
+}
+
+// drawRect returns the rectangle of the view image in which the given tile is
+// drawn at the provided coordinates.
+func (view *View) drawRect(col, row int, t tile.Tile) image.Rectangle {
+	// Align the tile based on the tile size of its own tileset.
+	r := view.GetTileRect(col, row, image.Rectangle{Max: t.Size})
+	r = r.Add(t.Offset)
+	return r.Add(view.origin)
+}
+
+// LayerContentRect returns the rectangle of the view image which contains the
+// tiles of the named layer. The boolean result is false if the layer doesn't
+// exist or has no tiles.
+func (view *View) LayerContentRect(name string) (image.Rectangle, bool) {
+	layer := view.layer(name)
+	if layer == nil {
+		return image.Rectangle{}, false
+	}
+	var bounds image.Rectangle
+	found := false
+	for row := 0; row < view.rows; row++ {
+		for col := 0; col < view.cols; col++ {
+			tile, ok := view.tileset[layer.GetGID(col, row)]
+			if !ok {
+				continue
 			}
+			bounds = bounds.Union(view.drawRect(col, row, tile))
+			found = true
+		}
+	}
+	return bounds, found
+}
+
+// drawOver draws the source image over the view image within the rectangle dr,
+// using the given opacity.
+func (view *View) drawOver(dr image.Rectangle, src image.Image, sp image.Point, opacity float64) {
+	if opacity <= 0 {
+		return
+	}
+	if opacity >= 1 {
+		draw.Draw(view, dr, src, sp, draw.Over)
+		return
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity*0xFF + 0.5)})
+	draw.DrawMask(view, dr, src, sp, mask, image.Point{}, draw.Over)
+}
+
+// Scaled returns a copy of the view image scaled by the given factor, using
+// nearest-neighbor interpolation to keep pixel art crisp.
+func (view *View) Scaled(factor float64) (image.Image, error) {
+	if factor <= 0 {
+		return nil, fmt.Errorf("Scaled: invalid scale factor %g.", factor)
+	}
+	return scale(view, factor), nil
+}
+
+// scale returns a copy of the image scaled by the given factor, using
+// nearest-neighbor interpolation.
+func scale(img image.Image, factor float64) *image.RGBA {
+	sr := img.Bounds()
+	width := int(float64(sr.Dx()) * factor)
+	height := int(float64(sr.Dy()) * factor)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := sr.Min.Y + int(float64(y)/factor)
+		for x := 0; x < width; x++ {
+			sx := sr.Min.X + int(float64(x)/factor)
+			dst.Set(x, y, img.At(sx, sy))
 		}
 	}
+	return dst
+}
+
+// min returns the smaller of x or y.
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// max returns the larger of x or y.
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
 }
@@ -0,0 +1,917 @@
+package mapview
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mewspring/tmx"
+	"github.com/mewspring/tmx/examples/mapview/tile"
+)
+
+// Colors of the tiles of the test tilesets.
+var (
+	red   = color.RGBA{R: 0xFF, A: 0xFF}
+	green = color.RGBA{G: 0xFF, A: 0xFF}
+	blue  = color.RGBA{B: 0xFF, A: 0xFF}
+)
+
+// writeTileset writes a tileset image of a single red tile of the given size to
+// the provided png file.
+func writeTileset(t *testing.T, pngPath string, width, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, red)
+		}
+	}
+	f, err := os.Create(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeSheet writes a sprite sheet of a single row of size by size tiles, each
+// filled with the corresponding color, to the provided png file.
+func writeSheet(t *testing.T, pngPath string, size int, colors ...color.RGBA) {
+	img := image.NewRGBA(image.Rect(0, 0, size*len(colors), size))
+	for i, c := range colors {
+		for y := 0; y < size; y++ {
+			for x := i * size; x < (i+1)*size; x++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	f, err := os.Create(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestView returns a view of the provided map source. The tilesets of the
+// map may use the sprite sheet "tiles.png", which contains a red, a green and a
+// blue tile of 16x16 pixels.
+func newTestView(t *testing.T, src string, opts ...Option) *View {
+	dir := t.TempDir()
+	writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red, green, blue)
+	m, err := tmx.NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(m, dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return view
+}
+
+// testTileset is the tileset of the sprite sheet written by newTestView.
+const testTileset = `
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="3" columns="3">
+  <image source="tiles.png" width="48" height="16"/>
+ </tileset>`
+
+// pixel is the expected color of a pixel.
+type pixel struct {
+	p    image.Point
+	want color.RGBA
+}
+
+// checkPixels reports an error for each pixel of img which differs from the
+// expected color.
+func checkPixels(t *testing.T, img image.Image, pixels []pixel) {
+	for _, g := range pixels {
+		got := color.RGBAModel.Convert(img.At(g.p.X, g.p.Y))
+		if got != g.want {
+			t.Errorf("pixel mismatch at %v; expected %v, got %v", g.p, g.want, got)
+		}
+	}
+}
+
+// newOffsetView returns a view of a 2x2 map of 16x16 tiles, with a single tile
+// at (0, 0) from a tileset with the given tile offset.
+func newOffsetView(t *testing.T, offset image.Point) *View {
+	dir := t.TempDir()
+	writeTileset(t, filepath.Join(dir, "red.png"), 16, 16)
+	src := fmt.Sprintf(`
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="red" tilewidth="16" tileheight="16">
+  <tileoffset x="%d" y="%d"/>
+  <image source="red.png" width="16" height="16"/>
+ </tileset>
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">1,0,0,0</data>
+ </layer>
+</map>`, offset.X, offset.Y)
+	m, err := tmx.NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(m, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view.Draw()
+	return view
+}
+
+func TestViewNegativeTileOffset(t *testing.T) {
+	view := newOffsetView(t, image.Pt(-8, -16))
+	// The canvas is extended up and to the left, so the tile is not clipped.
+	if got, want := view.Bounds(), image.Rect(0, 0, 40, 48); got != want {
+		t.Fatalf("view bounds mismatch; expected %v, got %v", want, got)
+	}
+	golden := []struct {
+		p    image.Point
+		want color.RGBA
+	}{
+		{p: image.Pt(0, 0), want: red},
+		{p: image.Pt(15, 15), want: red},
+		{p: image.Pt(16, 15), want: color.RGBA{}},
+		{p: image.Pt(15, 16), want: color.RGBA{}},
+		// Top-left corner of the map.
+		{p: image.Pt(8, 16), want: color.RGBA{}},
+	}
+	for _, g := range golden {
+		got := color.RGBAModel.Convert(view.At(g.p.X, g.p.Y))
+		if got != g.want {
+			t.Errorf("pixel mismatch at %v; expected %v, got %v", g.p, g.want, got)
+		}
+	}
+}
+
+func TestViewPositiveTileOffset(t *testing.T) {
+	// Tiles which are shifted within the map don't extend the canvas.
+	view := newOffsetView(t, image.Pt(8, 8))
+	if got, want := view.Bounds(), image.Rect(0, 0, 32, 32); got != want {
+		t.Fatalf("view bounds mismatch; expected %v, got %v", want, got)
+	}
+	if got := color.RGBAModel.Convert(view.At(8, 8)); got != red {
+		t.Errorf("pixel mismatch at (8, 8); expected %v, got %v", red, got)
+	}
+}
+
+func TestViewGolden(t *testing.T) {
+	m, err := tmx.Open("../../testdata/test_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(m, "../../testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view.Draw()
+	f, err := os.Open("cmd/tmxview/view.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := view.Bounds(), golden.Bounds(); got != want {
+		t.Fatalf("view bounds mismatch; expected %v, got %v", want, got)
+	}
+	b := golden.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			want := color.NRGBAModel.Convert(golden.At(x, y))
+			got := color.NRGBAModel.Convert(view.At(x, y))
+			if got != want {
+				t.Fatalf("pixel mismatch at (%d, %d); expected %v, got %v", x, y, want, got)
+			}
+		}
+	}
+}
+
+func TestViewDrawLayers(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="a" width="2" height="1">
+  <data encoding="csv">1,1</data>
+ </layer>
+ <layer name="b" width="2" height="1">
+  <data encoding="csv">0,3</data>
+ </layer>
+ <layer name="collision" width="2" height="1">
+  <data encoding="csv">2,2</data>
+ </layer>
+</map>`
+	golden := []struct {
+		names  []string
+		pixels []pixel
+	}{
+		{names: []string{"a", "b"}, pixels: []pixel{{image.Pt(0, 0), red}, {image.Pt(16, 0), blue}}},
+		// Layers are drawn in the given order.
+		{names: []string{"b", "a"}, pixels: []pixel{{image.Pt(0, 0), red}, {image.Pt(16, 0), red}}},
+		{names: []string{"b"}, pixels: []pixel{{image.Pt(0, 0), color.RGBA{}}, {image.Pt(16, 0), blue}}},
+		// The collision layer is drawn if requested explicitly.
+		{names: []string{"collision"}, pixels: []pixel{{image.Pt(0, 0), green}, {image.Pt(16, 0), green}}},
+	}
+	for _, g := range golden {
+		view := newTestView(t, src)
+		if err := view.DrawLayers(g.names); err != nil {
+			t.Errorf("%v: unexpected error; %v", g.names, err)
+			continue
+		}
+		checkPixels(t, view, g.pixels)
+	}
+	// Draw skips the collision layer.
+	view := newTestView(t, src)
+	view.Draw()
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), red}, {image.Pt(16, 0), blue}})
+}
+
+func TestViewDrawLayersMissing(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="a" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	if err := view.DrawLayers([]string{"a", "missing"}); err == nil {
+		t.Fatal("expected error for missing layer")
+	}
+	// No layer is drawn if a layer is missing.
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), color.RGBA{}}})
+	if err := view.DrawLayer("missing"); err == nil {
+		t.Error("expected error for missing layer")
+	}
+}
+
+func TestViewStaggered(t *testing.T) {
+	golden := []struct {
+		axis, index string
+		cols, rows  int
+		bounds      image.Rectangle
+		cells       map[image.Point]image.Point
+	}{
+		{
+			axis: "y", index: "odd", cols: 3, rows: 4,
+			bounds: image.Rect(0, 0, 112, 40),
+			cells: map[image.Point]image.Point{
+				{0, 0}: {0, 0},
+				{0, 1}: {16, 8},
+				{1, 2}: {32, 16},
+				{2, 3}: {80, 24},
+			},
+		},
+		{
+			axis: "y", index: "even", cols: 3, rows: 4,
+			bounds: image.Rect(0, 0, 112, 40),
+			cells: map[image.Point]image.Point{
+				{0, 0}: {16, 0},
+				{0, 1}: {0, 8},
+			},
+		},
+		{
+			axis: "x", index: "odd", cols: 4, rows: 3,
+			bounds: image.Rect(0, 0, 80, 56),
+			cells: map[image.Point]image.Point{
+				{0, 0}: {0, 0},
+				{1, 0}: {16, 8},
+				{3, 2}: {48, 40},
+			},
+		},
+		{
+			axis: "x", index: "even", cols: 4, rows: 3,
+			bounds: image.Rect(0, 0, 80, 56),
+			cells: map[image.Point]image.Point{
+				{0, 0}: {0, 8},
+				{1, 0}: {16, 0},
+			},
+		},
+	}
+	for _, g := range golden {
+		src := fmt.Sprintf(`
+<map version="1.0" orientation="staggered" renderorder="right-down" width="%d" height="%d" tilewidth="32" tileheight="16" staggeraxis="%s" staggerindex="%s">`+testTileset+`
+</map>`, g.cols, g.rows, g.axis, g.index)
+		view := newTestView(t, src)
+		if got := view.Bounds(); got != g.bounds {
+			t.Errorf("%s %s: view bounds mismatch; expected %v, got %v", g.axis, g.index, g.bounds, got)
+		}
+		for cell, min := range g.cells {
+			want := image.Rectangle{Min: min, Max: min.Add(image.Pt(32, 16))}
+			if got := view.GetCellRect(cell.X, cell.Y); got != want {
+				t.Errorf("%s %s: rectangle of cell %v mismatch; expected %v, got %v", g.axis, g.index, cell, want, got)
+			}
+		}
+	}
+}
+
+func TestViewDrawObjectsHidden(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <objectgroup name="objects">
+  <object id="1" gid="1" x="0" y="16" width="16" height="16"/>
+  <object id="2" gid="2" x="16" y="16" width="16" height="16" visible="0"/>
+ </objectgroup>
+</map>`
+	view := newTestView(t, src)
+	view.DrawObjects()
+	// Tile objects are aligned to the bottom-left.
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), red},
+		{image.Pt(15, 15), red},
+		{image.Pt(16, 0), color.RGBA{}},
+		{image.Pt(31, 15), color.RGBA{}},
+	})
+}
+
+func TestViewEmbeddedImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, green)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	src := fmt.Sprintf(`
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="embedded" tilewidth="16" tileheight="16">
+  <image format="png" width="16" height="16">
+   <data encoding="base64">%s</data>
+  </image>
+ </tileset>
+ <layer name="ground" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+</map>`, base64.StdEncoding.EncodeToString(buf.Bytes()))
+	view := newTestView(t, src)
+	view.Draw()
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), green}, {image.Pt(15, 15), green}})
+}
+
+func TestViewScaled(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">1,3</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	view.Draw()
+	golden := []struct {
+		factor float64
+		size   image.Point
+		pixels []pixel
+	}{
+		{factor: 1, size: image.Pt(32, 16), pixels: []pixel{{image.Pt(15, 0), red}, {image.Pt(16, 0), blue}}},
+		{factor: 2, size: image.Pt(64, 32), pixels: []pixel{{image.Pt(31, 31), red}, {image.Pt(32, 0), blue}}},
+		{factor: 0.5, size: image.Pt(16, 8), pixels: []pixel{{image.Pt(7, 7), red}, {image.Pt(8, 0), blue}}},
+	}
+	for _, g := range golden {
+		img, err := view.Scaled(g.factor)
+		if err != nil {
+			t.Errorf("factor=%g: unexpected error; %v", g.factor, err)
+			continue
+		}
+		if got := img.Bounds(); got != (image.Rectangle{Max: g.size}) {
+			t.Errorf("factor=%g: bounds mismatch; expected size %v, got %v", g.factor, g.size, got)
+		}
+		checkPixels(t, img, g.pixels)
+	}
+	for _, factor := range []float64{0, -1} {
+		if _, err := view.Scaled(factor); err == nil {
+			t.Errorf("factor=%g: expected error for invalid scale factor", factor)
+		}
+	}
+}
+
+func TestViewDrawObjectsOffset(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <objectgroup name="objects" offsetx="8" offsety="4">
+  <object id="1" gid="3" x="0" y="16" width="16" height="16"/>
+ </objectgroup>
+</map>`
+	view := newTestView(t, src)
+	view.DrawObjects()
+	checkPixels(t, view, []pixel{
+		{image.Pt(7, 3), color.RGBA{}},
+		{image.Pt(8, 4), blue},
+		{image.Pt(23, 19), blue},
+		{image.Pt(24, 20), color.RGBA{}},
+	})
+}
+
+func TestViewOrthogonal(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="ground" width="3" height="2">
+  <data encoding="csv">1,0,0,0,0,3</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	if got, want := view.Bounds(), image.Rect(0, 0, 48, 32); got != want {
+		t.Fatalf("view bounds mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := view.GetCellRect(0, 0), image.Rect(0, 0, 16, 16); got != want {
+		t.Errorf("rectangle of cell (0, 0) mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := view.GetCellRect(2, 1), image.Rect(32, 16, 48, 32); got != want {
+		t.Errorf("rectangle of cell (2, 1) mismatch; expected %v, got %v", want, got)
+	}
+	// The first and last cells are drawn within the canvas.
+	view.Draw()
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), red},
+		{image.Pt(15, 15), red},
+		{image.Pt(16, 16), color.RGBA{}},
+		{image.Pt(32, 16), blue},
+		{image.Pt(47, 31), blue},
+	})
+}
+
+func TestViewDrawOrder(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="a" width="2" height="1">
+  <data encoding="csv">1,0</data>
+ </layer>
+ <objectgroup name="objects">
+  <object id="1" gid="2" x="0" y="16" width="16" height="16"/>
+  <object id="2" gid="2" x="16" y="16" width="16" height="16"/>
+ </objectgroup>
+ <layer name="b" width="2" height="1">
+  <data encoding="csv">0,3</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	view.Draw()
+	// The object layer is drawn above layer a and below layer b.
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), green}, {image.Pt(16, 0), blue}})
+}
+
+func TestGetTilesetTooSmall(t *testing.T) {
+	// The sprite sheet contains 3 tiles, but local tile ID 3 is used.
+	golden := []struct {
+		gids  string
+		valid bool
+	}{
+		{gids: "1,3", valid: true},
+		{gids: "1,4", valid: false},
+	}
+	for _, g := range golden {
+		dir := t.TempDir()
+		writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red, green, blue)
+		src := `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16">
+  <image source="tiles.png" width="48" height="16"/>
+ </tileset>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">` + g.gids + `</data>
+ </layer>
+</map>`
+		m, err := tmx.NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = GetTileset(m, dir)
+		if g.valid && err != nil {
+			t.Errorf("%s: unexpected error; %v", g.gids, err)
+		}
+		if !g.valid && err == nil {
+			t.Errorf("%s: expected error for sprite sheet with too few tiles", g.gids)
+		}
+	}
+}
+
+func TestViewDrawObjectsOrder(t *testing.T) {
+	// The overlapping objects are drawn in the draw order of their layer.
+	const format = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <objectgroup name="objects" draworder="%s">
+  <object id="1" gid="1" x="0" y="24" width="16" height="16"/>
+  <object id="2" gid="3" x="0" y="16" width="16" height="16"/>
+ </objectgroup>
+</map>`
+	golden := []struct {
+		drawOrder string
+		want      color.RGBA
+	}{
+		{drawOrder: "topdown", want: red},
+		{drawOrder: "index", want: blue},
+	}
+	for _, g := range golden {
+		view := newTestView(t, fmt.Sprintf(format, g.drawOrder))
+		view.DrawObjects()
+		checkPixels(t, view, []pixel{{image.Pt(0, 10), g.want}})
+	}
+}
+
+func TestViewTileSizes(t *testing.T) {
+	// The image of the tall tileset is shorter than its tile height, as is the
+	// case for partial tiles at the edges of sprite sheets.
+	dir := t.TempDir()
+	writeTileset(t, filepath.Join(dir, "flat.png"), 64, 32)
+	writeTileset(t, filepath.Join(dir, "tall.png"), 64, 48)
+	const src = `
+<map version="1.0" orientation="isometric" width="2" height="2" tilewidth="64" tileheight="32">
+ <tileset firstgid="1" name="flat" tilewidth="64" tileheight="32">
+  <image source="flat.png" width="64" height="32"/>
+ </tileset>
+ <tileset firstgid="2" name="tall" tilewidth="64" tileheight="64">
+  <image source="tall.png" width="64" height="48"/>
+ </tileset>
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">2,0,0,1</data>
+ </layer>
+</map>`
+	m, err := tmx.NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := NewView(m, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tiles are aligned to the bottom of their cell, based on the tile size of
+	// their own tileset.
+	want := map[int]image.Rectangle{
+		1: image.Rect(32, 64, 96, 96),
+		2: image.Rect(32, 0, 96, 64),
+	}
+	n := 0
+	view.DrawFunc(func(col, row, gid int, dst image.Rectangle, _ tile.Tile) {
+		n++
+		if dst != want[gid] {
+			t.Errorf("rectangle of GID %d mismatch; expected %v, got %v", gid, want[gid], dst)
+		}
+	})
+	if n != len(want) {
+		t.Errorf("number of tiles mismatch; expected %d, got %d", len(want), n)
+	}
+}
+
+func TestNewViewUnsupportedOrientation(t *testing.T) {
+	m, err := tmx.NewFile(strings.NewReader(`<map version="1.0" orientation="hexagonal" width="1" height="1" tilewidth="32" tileheight="32"/>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewView(m, t.TempDir()); err == nil {
+		t.Error("expected error for unsupported orientation")
+	}
+}
+
+func TestViewDrawObjectsResized(t *testing.T) {
+	// Tile objects are drawn at the tile size of their tileset, regardless of
+	// the object size and the fill mode of the tileset.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="3" columns="3" fillmode="preserve-aspect-fit">
+  <image source="tiles.png" width="48" height="16"/>
+ </tileset>
+ <objectgroup name="objects">
+  <object id="1" gid="1" x="0" y="32" width="32" height="32"/>
+ </objectgroup>
+</map>`
+	view := newTestView(t, src)
+	view.DrawObjects()
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 16), red},
+		{image.Pt(15, 31), red},
+		{image.Pt(16, 31), color.RGBA{}},
+		{image.Pt(0, 15), color.RGBA{}},
+	})
+}
+
+func TestViewDrawRegion(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="a" width="3" height="2">
+  <data encoding="csv">1,1,1,1,1,1</data>
+ </layer>
+ <layer name="collision" width="3" height="2">
+  <data encoding="csv">2,2,2,2,2,2</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	// The range is clamped to the bounds of the map.
+	view.DrawRegion(1, -5, 10, 0)
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), color.RGBA{}},
+		{image.Pt(16, 0), red},
+		{image.Pt(47, 15), red},
+		{image.Pt(16, 16), color.RGBA{}},
+	})
+
+	// Only the cells of the range are redrawn.
+	view = newTestView(t, src)
+	view.Draw()
+	layer := &view.layers[0]
+	for col := 0; col < 3; col++ {
+		layer.SetRawGID(col, 1, 3)
+	}
+	view.DrawRegion(1, 1, 1, 1)
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 16), red},
+		{image.Pt(16, 16), blue},
+		{image.Pt(31, 31), blue},
+		{image.Pt(32, 16), red},
+	})
+
+	// An empty range draws nothing.
+	view = newTestView(t, src)
+	view.DrawRegion(2, 0, 1, 1)
+	checkPixels(t, view, []pixel{{image.Pt(16, 0), color.RGBA{}}, {image.Pt(32, 16), color.RGBA{}}})
+}
+
+func TestViewTrans(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="3" columns="3">
+  <image source="tiles.png" trans="ff0000" width="48" height="16"/>
+ </tileset>
+ <layer name="a" width="3" height="1">
+  <data encoding="csv">2,2,2</data>
+ </layer>
+ <layer name="b" width="3" height="1">
+  <data encoding="csv">1,3,0</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	view.Draw()
+	// Red pixels of the sprite sheet are transparent.
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), green}, {image.Pt(16, 0), blue}, {image.Pt(32, 0), green}})
+}
+
+func TestGetTilesetTransInvalid(t *testing.T) {
+	dir := t.TempDir()
+	writeSheet(t, filepath.Join(dir, "tiles.png"), 16, red)
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16">
+  <image source="tiles.png" trans="red" width="16" height="16"/>
+ </tileset>
+</map>`
+	m, err := tmx.NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetTileset(m, dir); err == nil {
+		t.Error("expected error for invalid transparent color")
+	}
+}
+
+func TestViewGroupLayers(t *testing.T) {
+	const src = `
+<map version="1.2" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="base" width="2" height="1">
+  <data encoding="csv">1,1</data>
+ </layer>
+ <group name="outer" opacity="0.5">
+  <layer name="a" width="2" height="1">
+   <data encoding="csv">2,0</data>
+  </layer>
+  <group name="inner" visible="0">
+   <layer name="b" width="2" height="1">
+    <data encoding="csv">0,3</data>
+   </layer>
+  </group>
+ </group>
+</map>`
+	// The green tile is drawn at half opacity over the red tile, and the
+	// layers of hidden group layers are skipped.
+	half := color.RGBA{R: 0x7F, G: 0x80, A: 0xFF}
+	view := newTestView(t, src)
+	view.Draw()
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), half}, {image.Pt(16, 0), red}})
+	view = newTestView(t, src)
+	view.DrawRegion(0, 0, 1, 0)
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), half}, {image.Pt(16, 0), red}})
+}
+
+func TestViewLayerContentRect(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="4" height="3" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="a" width="4" height="3">
+  <data encoding="csv">0,1,0,0,0,0,2,0,0,0,0,0</data>
+ </layer>
+ <layer name="empty" width="4" height="3">
+  <data encoding="csv">0,0,0,0,0,0,0,0,0,0,0,0</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	golden := []struct {
+		name string
+		want image.Rectangle
+		ok   bool
+	}{
+		{name: "a", want: image.Rect(16, 0, 48, 32), ok: true},
+		{name: "empty"},
+		{name: "missing"},
+	}
+	for _, g := range golden {
+		got, ok := view.LayerContentRect(g.name)
+		if ok != g.ok {
+			t.Errorf("%s: content mismatch; expected %v, got %v", g.name, g.ok, ok)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%s: content rectangle mismatch; expected %v, got %v", g.name, g.want, got)
+		}
+	}
+	// The content rectangle includes the tile offset of the tileset.
+	view = newOffsetView(t, image.Pt(4, -2))
+	got, ok := view.LayerContentRect("ground")
+	if !ok || got.Size() != image.Pt(16, 16) {
+		t.Fatalf("content rectangle mismatch; expected 16x16 rectangle, got %v (ok=%v)", got, ok)
+	}
+	checkPixels(t, view, []pixel{
+		{got.Min, red},
+		{got.Max.Sub(image.Pt(1, 1)), red},
+		{got.Min.Sub(image.Pt(1, 1)), color.RGBA{}},
+	})
+}
+
+func TestViewDrawFunc(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="a" width="2" height="2">
+  <data encoding="csv">0,1,2,0</data>
+ </layer>
+ <layer name="hidden" width="2" height="2" visible="0">
+  <data encoding="csv">1,1,1,1</data>
+ </layer>
+ <layer name="collision" width="2" height="2">
+  <data encoding="csv">2,2,2,2</data>
+ </layer>
+ <layer name="b" width="2" height="2">
+  <data encoding="csv">3,0,0,0</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	type call struct {
+		col, row, gid int
+		dst           image.Rectangle
+	}
+	// Layers are visited in document order, and tiles in row-major order.
+	want := []call{
+		{col: 1, row: 0, gid: 1, dst: image.Rect(16, 0, 32, 16)},
+		{col: 0, row: 1, gid: 2, dst: image.Rect(0, 16, 16, 32)},
+		{col: 0, row: 0, gid: 3, dst: image.Rect(0, 0, 16, 16)},
+	}
+	var got []call
+	view.DrawFunc(func(col, row, gid int, dst image.Rectangle, tile tile.Tile) {
+		if tile.Size != image.Pt(16, 16) {
+			t.Errorf("GID %d: tile size mismatch; expected 16x16, got %v", gid, tile.Size)
+		}
+		got = append(got, call{col: col, row: row, gid: gid, dst: dst})
+	})
+	if len(got) != len(want) {
+		t.Fatalf("number of tiles mismatch; expected %d, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("tile %d mismatch; expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	// The tiles are not drawn.
+	checkPixels(t, view, []pixel{{image.Pt(0, 0), color.RGBA{}}, {image.Pt(16, 0), color.RGBA{}}})
+}
+
+func TestViewDrawObjectsFlipped(t *testing.T) {
+	// The tile is red in its left half and green in its right half.
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, green)
+			}
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, "half.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		t.Fatal(err)
+	}
+	f.Close()
+	golden := []struct {
+		gid         tmx.GID
+		left, right color.RGBA
+	}{
+		{gid: tmx.MakeGID(1, false, false, false), left: red, right: green},
+		{gid: tmx.MakeGID(1, true, false, false), left: green, right: red},
+		// Flipped vertically, which leaves the columns unchanged.
+		{gid: tmx.MakeGID(1, false, true, false), left: red, right: green},
+	}
+	for _, g := range golden {
+		src := fmt.Sprintf(`
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="half" tilewidth="16" tileheight="16">
+  <image source="half.png" width="16" height="16"/>
+ </tileset>
+ <objectgroup name="objects">
+  <object id="1" gid="%d" x="0" y="16" width="16" height="16"/>
+ </objectgroup>
+</map>`, uint32(g.gid))
+		m, err := tmx.NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		view, err := NewView(m, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		view.DrawObjects()
+		checkPixels(t, view, []pixel{{image.Pt(2, 8), g.left}, {image.Pt(13, 8), g.right}})
+	}
+}
+
+func TestViewIsStaggered(t *testing.T) {
+	golden := []struct {
+		index string
+		want  map[int]bool
+	}{
+		{index: "odd", want: map[int]bool{-3: true, -2: false, -1: true, 0: false, 1: true, 2: false}},
+		{index: "even", want: map[int]bool{-3: false, -2: true, -1: false, 0: true, 1: false, 2: true}},
+	}
+	for _, g := range golden {
+		src := `
+<map version="1.0" orientation="staggered" width="3" height="4" tilewidth="32" tileheight="16" staggeraxis="y" staggerindex="` + g.index + `">` + testTileset + `
+</map>`
+		view := newTestView(t, src)
+		for i, want := range g.want {
+			if got := view.isStaggered(i); got != want {
+				t.Errorf("%s: isStaggered(%d) mismatch; expected %v, got %v", g.index, i, want, got)
+			}
+		}
+	}
+}
+
+func TestViewGetCellRectNegative(t *testing.T) {
+	golden := []struct {
+		orientation string
+		cell        image.Point
+		want        image.Point
+	}{
+		{orientation: "orthogonal", cell: image.Pt(-1, -2), want: image.Pt(-32, -32)},
+		// Odd rows are shifted by half a tile, also before the first row.
+		{orientation: `staggered" staggeraxis="y" staggerindex="odd`, cell: image.Pt(0, -1), want: image.Pt(16, -8)},
+		{orientation: `staggered" staggeraxis="y" staggerindex="odd`, cell: image.Pt(-1, -2), want: image.Pt(-32, -16)},
+		{orientation: `staggered" staggeraxis="y" staggerindex="odd`, cell: image.Pt(-1, -3), want: image.Pt(-16, -24)},
+	}
+	for _, g := range golden {
+		src := `
+<map version="1.0" orientation="` + g.orientation + `" width="3" height="4" tilewidth="32" tileheight="16">` + testTileset + `
+</map>`
+		view := newTestView(t, src)
+		want := image.Rectangle{Min: g.want, Max: g.want.Add(image.Pt(32, 16))}
+		if got := view.GetCellRect(g.cell.X, g.cell.Y); got != want {
+			t.Errorf("%s: rectangle of cell %v mismatch; expected %v, got %v", g.orientation, g.cell, want, got)
+		}
+	}
+}
+
+func TestViewLayerOpacityZero(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">` + testTileset + `
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">1,1</data>
+ </layer>
+ <layer name="hidden" width="2" height="1" opacity="0">
+  <data encoding="csv">2,2</data>
+ </layer>
+</map>`
+	view := newTestView(t, src)
+	view.Draw()
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), red},
+		{image.Pt(31, 15), red},
+	})
+	// Negative opacities leave the view image unchanged.
+	sheet := image.NewUniform(blue)
+	view.drawOver(image.Rect(0, 0, 16, 16), sheet, image.Point{}, -0.5)
+	checkPixels(t, view, []pixel{
+		{image.Pt(0, 0), red},
+		{image.Pt(15, 15), red},
+	})
+}
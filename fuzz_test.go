@@ -0,0 +1,82 @@
+package tmx
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzDecode(f *testing.F) {
+	tmxPaths, err := filepath.Glob("testdata/*.tmx")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, tmxPath := range tmxPaths {
+		buf, err := ioutil.ReadFile(tmxPath)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+	}
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		m, err := NewFile(bytes.NewReader(buf))
+		if err != nil {
+			return
+		}
+		for i := range m.Layers {
+			l := &m.Layers[i]
+			if m.Infinite {
+				// The dimensions of infinite maps are informational.
+				for _, c := range l.Data.Chunks {
+					for row := c.Y; row < c.Y+c.Height; row++ {
+						for col := c.X; col < c.X+c.Width; col++ {
+							l.GetRawGID(col, row)
+						}
+					}
+				}
+				continue
+			}
+			for row := 0; row < l.Height; row++ {
+				for col := 0; col < l.Width; col++ {
+					l.GetRawGID(col, row)
+				}
+			}
+		}
+	})
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	golden := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "overflowing dimensions",
+			src:  `<map width="4294967296" height="4294967296"><layer name="a"><data encoding="csv"></data></layer></map>`,
+		},
+		{
+			name: "huge width",
+			src:  `<map width="4294967296" height="0"><layer name="a"><data encoding="csv"></data></layer></map>`,
+		},
+		{
+			name: "negative layer dimensions",
+			src:  `<map width="2" height="2"><layer name="a" width="-2" height="-2"><data encoding="csv"></data></layer></map>`,
+		},
+		{
+			name: "truncated base64",
+			src:  `<map width="2" height="1"><layer name="a"><data encoding="base64">AQAAAAI=</data></layer></map>`,
+		},
+		{
+			name: "overflowing chunk dimensions",
+			src:  `<map width="2" height="2" infinite="1"><layer name="a"><data encoding="csv"><chunk x="0" y="0" width="4294967296" height="4294967296"></chunk></data></layer></map>`,
+		},
+	}
+	for _, g := range golden {
+		_, err := NewFile(strings.NewReader(g.src))
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", g.name)
+		}
+	}
+}
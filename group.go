@@ -0,0 +1,116 @@
+package tmx
+
+import "sort"
+
+// flattenGroups moves the layers of the group layers of the map to Layers,
+// ObjectLayers, ImageLayers and Groups. The tile and object layers are sorted in
+// document order, and keep track of the group layer which contains them.
+func (m *Map) flattenGroups() {
+	var groups []GroupLayer
+	var parents []int
+	var layerGroups, objectLayerGroups []int
+	var layers []Layer
+	var objectLayers []ObjectLayer
+	var collect func(gs []GroupLayer, parent int)
+	collect = func(gs []GroupLayer, parent int) {
+		for _, g := range gs {
+			index := len(groups)
+			groups = append(groups, g)
+			parents = append(parents, parent)
+			for _, l := range g.layers {
+				layers = append(layers, l)
+				layerGroups = append(layerGroups, index)
+			}
+			for _, ol := range g.objectLayers {
+				objectLayers = append(objectLayers, ol)
+				objectLayerGroups = append(objectLayerGroups, index)
+			}
+			m.ImageLayers = append(m.ImageLayers, g.imageLayers...)
+			collect(g.groups, index)
+		}
+	}
+	collect(m.Groups, -1)
+	if len(groups) == 0 {
+		return
+	}
+	// The group layers are not moved once their addresses have been taken.
+	m.Groups = groups
+	for i := range m.Groups {
+		g := &m.Groups[i]
+		g.layers, g.objectLayers, g.imageLayers, g.groups = nil, nil, nil, nil
+		if parents[i] != -1 {
+			g.Parent = &m.Groups[parents[i]]
+		}
+	}
+	for i := range layers {
+		layers[i].Group = &m.Groups[layerGroups[i]]
+	}
+	for i := range objectLayers {
+		objectLayers[i].Group = &m.Groups[objectLayerGroups[i]]
+	}
+	m.Layers = append(m.Layers, layers...)
+	m.ObjectLayers = append(m.ObjectLayers, objectLayers...)
+	sort.Stable(layersByOffset(m.Layers))
+	sort.Stable(objectLayersByOffset(m.ObjectLayers))
+}
+
+// layersByOffset implements sort.Interface, sorting layers by their position in
+// the tmx file.
+type layersByOffset []Layer
+
+func (s layersByOffset) Len() int           { return len(s) }
+func (s layersByOffset) Less(i, j int) bool { return s[i].offset < s[j].offset }
+func (s layersByOffset) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// objectLayersByOffset implements sort.Interface, sorting object layers by
+// their position in the tmx file.
+type objectLayersByOffset []ObjectLayer
+
+func (s objectLayersByOffset) Len() int           { return len(s) }
+func (s objectLayersByOffset) Less(i, j int) bool { return s[i].offset < s[j].offset }
+func (s objectLayersByOffset) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// EffectiveVisible returns true if the layer and every group layer which
+// contains it are visible.
+func (l *Layer) EffectiveVisible() bool {
+	return l.Visible && l.Group.effectiveVisible()
+}
+
+// EffectiveOpacity returns the opacity of the layer multiplied by the opacity
+// of every group layer which contains it.
+func (l *Layer) EffectiveOpacity() float64 {
+	return l.Opacity * l.Group.effectiveOpacity()
+}
+
+// EffectiveVisible returns true if the object layer and every group layer
+// which contains it are visible.
+func (ol *ObjectLayer) EffectiveVisible() bool {
+	return ol.Visible && ol.Group.effectiveVisible()
+}
+
+// EffectiveOpacity returns the opacity of the object layer multiplied by the
+// opacity of every group layer which contains it.
+func (ol *ObjectLayer) EffectiveOpacity() float64 {
+	return ol.Opacity * ol.Group.effectiveOpacity()
+}
+
+// effectiveVisible returns true if the group layer and all of its ancestors
+// are visible. A nil group layer is visible.
+func (g *GroupLayer) effectiveVisible() bool {
+	for ; g != nil; g = g.Parent {
+		if !g.Visible {
+			return false
+		}
+	}
+	return true
+}
+
+// effectiveOpacity returns the opacity of the group layer multiplied by the
+// opacity of its ancestors. A nil group layer is opaque.
+func (g *GroupLayer) effectiveOpacity() float64 {
+	opacity := 1.0
+	for ; g != nil; g = g.Parent {
+		opacity *= g.Opacity
+	}
+	return opacity
+}
@@ -0,0 +1,85 @@
+package tmx
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupLayers(t *testing.T) {
+	const src = `
+<map version="1.2" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <layer name="base" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+ <group name="outer" opacity="0.5">
+  <layer name="a" width="1" height="1" opacity="0.5">
+   <data encoding="csv">2</data>
+  </layer>
+  <group name="inner" visible="0">
+   <objectgroup name="o"/>
+   <layer name="b" width="1" height="1">
+    <data encoding="csv">3</data>
+   </layer>
+  </group>
+ </group>
+ <layer name="top" width="1" height="1">
+  <data encoding="csv">4</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Groups) != 2 {
+		t.Fatalf("number of group layers mismatch; expected 2, got %d", len(m.Groups))
+	}
+	outer, inner := &m.Groups[0], &m.Groups[1]
+	if outer.Name != "outer" || outer.Parent != nil {
+		t.Errorf("group layer mismatch; expected outer group layer %q, got %q (parent=%p)", "outer", outer.Name, outer.Parent)
+	}
+	if inner.Name != "inner" || inner.Parent != outer {
+		t.Errorf("group layer mismatch; expected group layer %q nested in %q, got %q (parent=%p)", "inner", "outer", inner.Name, inner.Parent)
+	}
+	// Tile layers of group layers are flattened in document order.
+	golden := []struct {
+		name    string
+		group   *GroupLayer
+		gid     int
+		visible bool
+		opacity float64
+	}{
+		{name: "base", gid: 1, visible: true, opacity: 1},
+		{name: "a", group: outer, gid: 2, visible: true, opacity: 0.25},
+		{name: "b", group: inner, gid: 3, visible: false, opacity: 0.5},
+		{name: "top", gid: 4, visible: true, opacity: 1},
+	}
+	if len(m.Layers) != len(golden) {
+		t.Fatalf("number of layers mismatch; expected %d, got %d", len(golden), len(m.Layers))
+	}
+	for i, g := range golden {
+		l := &m.Layers[i]
+		if l.Name != g.name {
+			t.Errorf("layer %d: name mismatch; expected %q, got %q", i, g.name, l.Name)
+			continue
+		}
+		if l.Group != g.group {
+			t.Errorf("layer %q: group layer mismatch; expected %p, got %p", l.Name, g.group, l.Group)
+		}
+		if gid := l.GetGID(0, 0); gid != g.gid {
+			t.Errorf("layer %q: GID mismatch; expected %d, got %d", l.Name, g.gid, gid)
+		}
+		if got := l.EffectiveVisible(); got != g.visible {
+			t.Errorf("layer %q: visibility mismatch; expected %v, got %v", l.Name, g.visible, got)
+		}
+		if got := l.EffectiveOpacity(); got != g.opacity {
+			t.Errorf("layer %q: opacity mismatch; expected %v, got %v", l.Name, g.opacity, got)
+		}
+	}
+	if len(m.ObjectLayers) != 1 {
+		t.Fatalf("number of object layers mismatch; expected 1, got %d", len(m.ObjectLayers))
+	}
+	ol := &m.ObjectLayers[0]
+	if ol.Group != inner || ol.EffectiveVisible() || ol.EffectiveOpacity() != 0.5 {
+		t.Errorf("object layer mismatch; expected hidden layer of group layer %q with opacity 0.5, got visible=%v, opacity=%v", "inner", ol.EffectiveVisible(), ol.EffectiveOpacity())
+	}
+}
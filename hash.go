@@ -0,0 +1,162 @@
+package tmx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentHash returns a SHA-256 hash of the meaningful content of the map; its
+// attributes, editor settings and properties, its tilesets (including embedded
+// image data, tile information and Wang sets), the decoded GIDs of its tile
+// layers, its object layers and objects, its image layers and its group layers.
+// The hash is independent of the XML formatting of the tmx file and of the
+// encoding and compression of the layer and image data.
+//
+// Note: The Warnings of the map are not part of its content, and are excluded
+// from the hash.
+func (m *Map) ContentHash() [32]byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "map %q %q %q %d %d %d %d %q %q %t %d %d\n", m.Version, m.Orientation, m.RenderOrder, m.Width, m.Height, m.TileWidth, m.TileHeight, m.StaggerAxis, m.StaggerIndex, m.Infinite, m.NextLayerID, m.NextObjectID)
+	fmt.Fprintf(h, "chunksize %d %d\n", m.EditorSettings.ChunkSize.Width, m.EditorSettings.ChunkSize.Height)
+	writeProperties(h, m.Properties)
+	for i := range m.Tilesets {
+		writeTileset(h, &m.Tilesets[i])
+	}
+	for i := range m.Groups {
+		g := &m.Groups[i]
+		fmt.Fprintf(h, "group %d %q %t %g %d\n", g.ID, g.Name, g.Visible, g.Opacity, m.groupIndex(g.Parent))
+		writeProperties(h, g.Properties)
+	}
+	for i := range m.Layers {
+		l := &m.Layers[i]
+		fmt.Fprintf(h, "layer %d %d %q %d %d %t %t %g %d\n", l.Index, l.ID, l.Name, l.Width, l.Height, l.Visible, l.Locked, l.Opacity, m.groupIndex(l.Group))
+		writeProperties(h, l.Properties)
+		l.writeGIDs(h)
+	}
+	for _, ol := range m.ObjectLayers {
+		fmt.Fprintf(h, "objectgroup %d %d %q %t %g %d %d %q %d\n", ol.Index, ol.ID, ol.Name, ol.Visible, ol.Opacity, ol.OffsetX, ol.OffsetY, ol.DrawOrder, m.groupIndex(ol.Group))
+		for _, o := range ol.Objects {
+			fmt.Fprintf(h, "object %d %q %q %g %g %g %g %g %t %d\n", o.ID, o.Name, o.Type, o.X, o.Y, o.Width, o.Height, o.Rotation, o.Visible, o.GID)
+			writeProperties(h, o.Properties)
+			// Normalize the whitespace between points.
+			fmt.Fprintf(h, "polygon %q\n", strings.Join(strings.Fields(o.Polygon.Points), " "))
+			fmt.Fprintf(h, "polyline %q\n", strings.Join(strings.Fields(o.Polyline.Points), " "))
+			if t := o.Text; t != nil {
+				fmt.Fprintf(h, "text %q %d %t %q %t %t %q %q %q\n", t.FontFamily, t.PixelSize, t.Wrap, t.Color, t.Bold, t.Italic, t.HAlign, t.VAlign, t.Text)
+			}
+		}
+	}
+	for _, il := range m.ImageLayers {
+		fmt.Fprintf(h, "imagelayer %d %q %t %g %d %d\n", il.ID, il.Name, il.Visible, il.Opacity, il.OffsetX, il.OffsetY)
+		writeProperties(h, il.Properties)
+		writeImage(h, il.Image)
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// groupIndex returns the index of the given group layer in Groups, or -1 if g
+// is nil.
+func (m *Map) groupIndex(g *GroupLayer) int {
+	for i := range m.Groups {
+		if &m.Groups[i] == g {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeTileset writes a canonical representation of the tileset to w.
+func writeTileset(w io.Writer, ts *Tileset) {
+	fmt.Fprintf(w, "tileset %d %q %q %d %d %d %d %d %q %d %d\n", ts.FirstGID, ts.Source, ts.Name, ts.TileWidth, ts.TileHeight, ts.Spacing, ts.Margin, ts.TileCount, ts.FillMode, ts.TileOffset.X, ts.TileOffset.Y)
+	writeProperties(w, ts.Properties)
+	for _, img := range ts.Images {
+		writeImage(w, img)
+	}
+	for _, t := range ts.TilesInfo {
+		fmt.Fprintf(w, "tile %d %q %g\n", t.ID, t.Class, t.Probability)
+		writeProperties(w, t.Properties)
+		if t.Image != nil {
+			writeImage(w, *t.Image)
+		}
+		for _, f := range t.Animation.Frames {
+			fmt.Fprintf(w, "frame %d %d\n", f.TileID, f.Duration)
+		}
+	}
+	for _, ws := range ts.WangSets {
+		fmt.Fprintf(w, "wangset %q %q %d\n", ws.Name, ws.Type, ws.Tile)
+		writeProperties(w, ws.Properties)
+		for _, c := range ws.WangColors {
+			fmt.Fprintf(w, "wangcolor %q %q %d %g\n", c.Name, c.Color, c.Tile, c.Probability)
+			writeProperties(w, c.Properties)
+		}
+		for _, t := range ws.WangTiles {
+			fmt.Fprintf(w, "wangtile %d %v\n", t.TileID, t.WangID)
+		}
+	}
+}
+
+// writeImage writes a canonical representation of the image to w. Embedded
+// image data is represented by its decoded contents, or by its raw data without
+// whitespace if it cannot be decoded.
+func writeImage(w io.Writer, img Image) {
+	fmt.Fprintf(w, "image %q %q %q %d %d\n", img.Format, img.Source, img.Trans, img.Width, img.Height)
+	if img.Data == nil {
+		return
+	}
+	buf, err := img.Data.Bytes()
+	if err != nil {
+		fmt.Fprintf(w, "rawdata %q\n", strings.Map(stripSpace, img.Data.RawData))
+		return
+	}
+	fmt.Fprintf(w, "data %x\n", sha256.Sum256(buf))
+}
+
+// Hash returns a SHA-256 hash of the raw global tile IDs of the layer,
+// including the flip flags. Layers with identical global tile IDs have the same
+// hash, which makes it possible to detect changes to a layer.
+func (l *Layer) Hash() [32]byte {
+	h := sha256.New()
+	l.writeGIDs(h)
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// writeGIDs writes a canonical representation of the raw global tile IDs of the
+// layer to w, including the chunks of layers in infinite maps.
+func (l *Layer) writeGIDs(w io.Writer) {
+	l.decoded()
+	writeGrid(w, l.Data.gids)
+	for i := range l.Data.Chunks {
+		c := &l.Data.Chunks[i]
+		fmt.Fprintf(w, "chunk %d %d %d %d\n", c.X, c.Y, c.Width, c.Height)
+		gids, err := c.gids()
+		if err != nil {
+			panic(fmt.Sprintf("tmx.Layer.writeGIDs: unable to decode chunk of layer '%s'; %v", l.Name, err))
+		}
+		writeGrid(w, gids)
+	}
+}
+
+// writeProperties writes a canonical representation of the properties to w.
+func writeProperties(w io.Writer, props Properties) {
+	for _, p := range props {
+		fmt.Fprintf(w, "property %q %q %q\n", p.Name, p.Type, p.Value)
+	}
+}
+
+// writeGrid writes a canonical representation of the grid of raw global tile
+// IDs to w, in row-major order.
+func writeGrid(w io.Writer, gids [][]GID) {
+	fmt.Fprintf(w, "grid %d\n", len(gids))
+	for row := 0; len(gids) > 0 && row < len(gids[0]); row++ {
+		for col := range gids {
+			fmt.Fprintf(w, "%d,", gids[col][row])
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,161 @@
+package tmx
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMapContentHashFormatting(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/test_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(buf)
+	// Reformat the tmx file, by removing the indentation, using single quotes
+	// for attribute values and reflowing the csv data.
+	reformatted := regexp.MustCompile(`\n\s+`).ReplaceAllString(src, "\n")
+	reformatted = regexp.MustCompile(`="([^"]*)"`).ReplaceAllString(reformatted, "='$1'")
+	reformatted = strings.Replace(reformatted, ",\n", ", ", -1)
+	if reformatted == src {
+		t.Fatal("reformatted tmx file identical to original")
+	}
+	m1, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := NewFile(strings.NewReader(reformatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1.ContentHash() != m2.ContentHash() {
+		t.Error("content hash mismatch between map and reformatted map")
+	}
+}
+
+func TestMapContentHashEncoding(t *testing.T) {
+	// The test maps contain the same map using different layer data encodings.
+	var want [32]byte
+	for i, tmxPath := range []string{"testdata/test_csv.tmx", "testdata/test_base64.tmx", "testdata/test_base64_gzip.tmx", "testdata/test_base64_zlib.tmx", "testdata/test_xml.tmx"} {
+		m, err := Open(tmxPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := m.ContentHash()
+		if i == 0 {
+			want = got
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: content hash mismatch", tmxPath)
+		}
+	}
+}
+
+func TestMapContentHashContent(t *testing.T) {
+	const base = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <editorsettings><chunksize width="16" height="16"/></editorsettings>
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" fillmode="stretch">
+  <image format="png"><data encoding="base64">AAAA</data></image>
+  <wangsets><wangset name="terrain" type="corner" tile="-1"><wangcolor name="grass" color="#00ff00" tile="-1"/></wangset></wangsets>
+ </tileset>
+ <group name="g">
+  <layer name="ground" width="1" height="1"><data encoding="csv">1</data></layer>
+ </group>
+ <imagelayer name="sky"><image source="sky.png"/></imagelayer>
+</map>`
+	changes := []struct {
+		name     string
+		old, new string
+	}{
+		{name: "embedded image data", old: "AAAA", new: "AAAB"},
+		{name: "image format", old: `format="png"`, new: `format="bmp"`},
+		{name: "fill mode", old: `fillmode="stretch"`, new: `fillmode="preserve-aspect-fit"`},
+		{name: "chunk size", old: `width="16"`, new: `width="32"`},
+		{name: "wang color", old: `color="#00ff00"`, new: `color="#0000ff"`},
+		{name: "image layer", old: `sky.png`, new: `clouds.png`},
+		{name: "group layer", old: `<group name="g">`, new: `<group name="h">`},
+	}
+	m, err := NewFile(strings.NewReader(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := m.ContentHash()
+	for _, c := range changes {
+		src := strings.Replace(base, c.old, c.new, 1)
+		m, err := NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if m.ContentHash() == want {
+			t.Errorf("%s: content hash unchanged", c.name)
+		}
+	}
+}
+
+func TestLayerHash(t *testing.T) {
+	// The test maps contain the same layers using different layer data
+	// encodings.
+	var want [32]byte
+	for i, tmxPath := range []string{"testdata/test_csv.tmx", "testdata/test_base64_zlib.tmx", "testdata/test_xml.tmx"} {
+		m, err := Open(tmxPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := m.Layers[0].Hash()
+		if i == 0 {
+			want = got
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: layer hash mismatch", tmxPath)
+		}
+	}
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "1,2,3,4")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	orig := l.Hash()
+	// The hash only depends on the GIDs of the layer.
+	l.Name, l.Opacity = "renamed", 0.5
+	if l.Hash() != orig {
+		t.Error("layer hash changed by layer attributes")
+	}
+	// Changes to GIDs and flip flags are detected.
+	l.SetRawGID(1, 1, 5)
+	if l.Hash() == orig {
+		t.Error("layer hash unchanged by modified GID")
+	}
+	l.SetRawGID(1, 1, MakeGID(4, true, false, false))
+	if l.Hash() == orig {
+		t.Error("layer hash unchanged by flipped GID")
+	}
+	l.SetRawGID(1, 1, 4)
+	if l.Hash() != orig {
+		t.Error("layer hash mismatch after restoring GID")
+	}
+}
+
+func TestLayerHashChunked(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	orig := l.Hash()
+	l.SetRawGID(-32, -32, 1)
+	if l.Hash() == orig {
+		t.Error("layer hash unchanged by modified GID in chunk")
+	}
+	// The chunks of each layer are taken into account.
+	m2, err := NewFile(strings.NewReader(strings.Replace(chunkedMap, `x="-32"`, `x="-30"`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2.Layers[0].Hash() == orig {
+		t.Error("layer hash unchanged by moved chunk")
+	}
+}
@@ -0,0 +1,352 @@
+package tmx
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strconv"
+	"unsafe"
+)
+
+// ContentBounds returns the smallest rectangle, in tile units, which contains
+// every non-empty cell of the layer. The rectangle is half-open, i.e. Max is
+// one past the last non-empty column and row. The coordinates of layers in
+// infinite maps may be negative (see OriginOffset). The boolean result is false
+// if the layer has no non-empty cells.
+func (l *Layer) ContentBounds() (image.Rectangle, bool) {
+	var bounds image.Rectangle
+	found := false
+	l.eachRawGID(func(col, row int, gid GID) {
+		if gid.GlobalTileID() == 0 {
+			return
+		}
+		cell := image.Rect(col, row, col+1, row+1)
+		if !found {
+			bounds = cell
+			found = true
+			return
+		}
+		bounds = bounds.Union(cell)
+	})
+	return bounds, found
+}
+
+// eachRawGID invokes fn for each cell of the layer, together with its raw
+// global tile ID. The cells of layers in infinite maps are visited chunk by
+// chunk, using the coordinates of the map.
+func (l *Layer) eachRawGID(fn func(col, row int, gid GID)) {
+	if l.Data == nil {
+		return
+	}
+	l.decoded()
+	if l.Data.chunked() {
+		for _, c := range l.Data.Chunks {
+			for row := c.Y; row < c.Y+c.Height; row++ {
+				for col := c.X; col < c.X+c.Width; col++ {
+					fn(col, row, l.GetRawGID(col, row))
+				}
+			}
+		}
+		return
+	}
+	for col := range l.Data.gids {
+		for row, gid := range l.Data.gids[col] {
+			fn(col, row, gid)
+		}
+	}
+}
+
+// A SafeLayer provides access to the GIDs of a layer without panicking. The
+// empty GID 0 is returned for coordinates outside of the layer and for layers
+// which cannot be decoded.
+type SafeLayer struct {
+	// The underlying layer.
+	layer *Layer
+}
+
+// Safe returns a SafeLayer which provides access to the GIDs of the layer
+// without panicking.
+func (l *Layer) Safe() SafeLayer {
+	return SafeLayer{layer: l}
+}
+
+// GID returns the global tile ID at a given coordinate, after clearing the flip
+// flags.
+func (sl SafeLayer) GID(col, row int) int {
+	return sl.RawGID(col, row).GlobalTileID()
+}
+
+// RawGID returns the global tile ID at a given coordinate, without clearing the
+// flip flags.
+func (sl SafeLayer) RawGID(col, row int) GID {
+	if sl.layer == nil || sl.layer.Data == nil || sl.layer.decodeData() != nil {
+		return 0
+	}
+	if sl.layer.Data.chunked() {
+		gid, err := sl.layer.Data.chunkGID(col, row)
+		if err != nil {
+			return 0
+		}
+		return gid
+	}
+	gids := sl.layer.Data.gids
+	if col < 0 || col >= len(gids) || row < 0 || row >= len(gids[col]) {
+		return 0
+	}
+	return gids[col][row]
+}
+
+// A Cell contains the global tile ID and flip flags of a cell of a tile layer.
+type Cell struct {
+	// The global tile ID, after clearing the flip flags.
+	GID int
+	// H, V and D specify whether the tile is flipped horizontally, vertically
+	// and diagonally respectively.
+	H, V, D bool
+}
+
+// TileGrid returns the cells of the layer, arranged by col and row.
+func (l *Layer) TileGrid() [][]Cell {
+	l.decoded()
+	cells := make([][]Cell, len(l.Data.gids))
+	for col, gids := range l.Data.gids {
+		cells[col] = make([]Cell, len(gids))
+		for row, gid := range gids {
+			cells[col][row] = Cell{
+				GID: gid.GlobalTileID(),
+				H:   gid&FlagHorizontalFlip != 0,
+				V:   gid&FlagVerticalFlip != 0,
+				D:   gid&FlagDiagonalFlip != 0,
+			}
+		}
+	}
+	return cells
+}
+
+// OriginOffset returns the minimum tile coordinate of the chunks of a layer in
+// an infinite map, which may be negative. Subtracting the offset translates the
+// layer coordinates into a 0-based grid. The offset of layers in finite maps is
+// always (0, 0).
+func (l *Layer) OriginOffset() image.Point {
+	var origin image.Point
+	for i, c := range l.Data.Chunks {
+		if i == 0 {
+			origin = image.Pt(c.X, c.Y)
+			continue
+		}
+		origin.X = min(origin.X, c.X)
+		origin.Y = min(origin.Y, c.Y)
+	}
+	return origin
+}
+
+// RawGrid returns the raw global tile IDs of the layer, including the flip
+// flags, arranged by col and row. Layers of infinite maps have no such grid, and
+// nil is returned.
+//
+// Note: The returned grid is shared with the layer and must be treated as
+// read-only. Use SetRawGID to modify the layer.
+func (l *Layer) RawGrid() [][]GID {
+	l.decoded()
+	return l.Data.gids
+}
+
+// GIDsRowMajor returns the global tile IDs of the layer in row-major order,
+// after clearing the flip flags. Layers of infinite maps have no cell grid (see
+// RawGrid), and nil is returned.
+func (l *Layer) GIDsRowMajor() []int {
+	raw := l.RawGIDsRowMajor()
+	if raw == nil {
+		return nil
+	}
+	gids := make([]int, len(raw))
+	for i, gid := range raw {
+		gids[i] = gid.GlobalTileID()
+	}
+	return gids
+}
+
+// RawGIDsRowMajor returns the raw global tile IDs of the layer in row-major
+// order, including the flip flags. Layers of infinite maps have no cell grid
+// (see RawGrid), and nil is returned.
+func (l *Layer) RawGIDsRowMajor() []GID {
+	l.decoded()
+	grid := l.Data.gids
+	if grid == nil {
+		return nil
+	}
+	var gids []GID
+	for row := 0; len(grid) > 0 && row < len(grid[0]); row++ {
+		for col := range grid {
+			gids = append(gids, grid[col][row])
+		}
+	}
+	return gids
+}
+
+// ASCII returns a textual representation of the layer, intended for debugging.
+// Each row of the layer is written on a separate line, with the GIDs of the
+// cells separated by spaces, after clearing the flip flags. Empty cells are
+// represented by '.'.
+func (l *Layer) ASCII() string {
+	l.decoded()
+	gids := l.Data.gids
+	if len(gids) == 0 {
+		return ""
+	}
+	buf := new(bytes.Buffer)
+	for row := range gids[0] {
+		for col := range gids {
+			if col > 0 {
+				buf.WriteByte(' ')
+			}
+			gid := gids[col][row].GlobalTileID()
+			if gid == 0 {
+				buf.WriteByte('.')
+				continue
+			}
+			buf.WriteString(strconv.Itoa(gid))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// IndexImage returns an image of the layer in which the value of each pixel is
+// the global tile ID of the corresponding cell, after clearing the flip flags.
+// Pixel (x, y) corresponds to column x and row y of the layer.
+//
+// Note: Global tile IDs above 65535, the ceiling of the 16-bit pixel depth, are
+// saturated to 65535.
+func (l *Layer) IndexImage() *image.Gray16 {
+	l.decoded()
+	gids := l.Data.gids
+	rows := 0
+	if len(gids) > 0 {
+		rows = len(gids[0])
+	}
+	img := image.NewGray16(image.Rect(0, 0, len(gids), rows))
+	for col := range gids {
+		for row := range gids[col] {
+			gid := gids[col][row].GlobalTileID()
+			if gid > 0xFFFF {
+				gid = 0xFFFF
+			}
+			img.SetGray16(col, row, color.Gray16{Y: uint16(gid)})
+		}
+	}
+	return img
+}
+
+// MemUsage returns the approximate number of bytes used by the decoded global
+// tile IDs of the layer, including the slice headers of the grid and of the
+// chunks of infinite maps. Layers which have not yet been decoded (see
+// WithLayers) use no memory for their global tile IDs, while the chunks of
+// infinite maps are decoded to determine their memory usage.
+func (l *Layer) MemUsage() int {
+	if l.Data == nil {
+		return 0
+	}
+	n := gridMemUsage(l.Data.gids)
+	for i := range l.Data.Chunks {
+		if gids, err := l.Data.Chunks[i].gids(); err == nil {
+			n += gridMemUsage(gids)
+		}
+	}
+	return n
+}
+
+// MemUsage returns the approximate number of bytes used by the decoded global
+// tile IDs of all tile layers of the map.
+func (m *Map) MemUsage() int {
+	n := 0
+	for i := range m.Layers {
+		n += m.Layers[i].MemUsage()
+	}
+	return n
+}
+
+// gridMemUsage returns the approximate number of bytes used by the given grid
+// of global tile IDs.
+func gridMemUsage(gids [][]GID) int {
+	if gids == nil {
+		return 0
+	}
+	n := int(unsafe.Sizeof(gids)) + cap(gids)*int(unsafe.Sizeof([]GID(nil)))
+	for _, col := range gids {
+		n += cap(col) * int(unsafe.Sizeof(GID(0)))
+	}
+	return n
+}
+
+// ConnectedRegions returns the regions of 4-connected non-empty cells of the
+// layer, as lists of cell coordinates. If sameGID is true, the cells of a
+// region must also have the same global tile ID, ignoring the flip flags.
+// Regions are ordered by their first cell in row-major order.
+//
+// Note: Layers of infinite maps have no cell grid (see RawGrid), and no regions
+// are returned.
+func (l *Layer) ConnectedRegions(sameGID bool) [][]image.Point {
+	l.decoded()
+	gids := l.Data.gids
+	visited := make([][]bool, len(gids))
+	for col := range gids {
+		visited[col] = make([]bool, len(gids[col]))
+	}
+	connected := func(p, q image.Point) bool {
+		if q.X < 0 || q.X >= len(gids) || q.Y < 0 || q.Y >= len(gids[q.X]) {
+			return false
+		}
+		gid := gids[q.X][q.Y].GlobalTileID()
+		if gid == 0 {
+			return false
+		}
+		return !sameGID || gid == gids[p.X][p.Y].GlobalTileID()
+	}
+	neighbours := [...]image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	var regions [][]image.Point
+	for row := 0; len(gids) > 0 && row < len(gids[0]); row++ {
+		for col := range gids {
+			if visited[col][row] || gids[col][row].GlobalTileID() == 0 {
+				continue
+			}
+			// Flood fill the region, using the region itself as the queue.
+			visited[col][row] = true
+			region := []image.Point{{col, row}}
+			for i := 0; i < len(region); i++ {
+				p := region[i]
+				for _, d := range neighbours {
+					q := p.Add(d)
+					if !connected(p, q) || visited[q.X][q.Y] {
+						continue
+					}
+					visited[q.X][q.Y] = true
+					region = append(region, q)
+				}
+			}
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// RotateTileCW rotates the tile at a given coordinate 90 degrees clockwise, by
+// updating its flip flags. Four rotations restore the original orientation.
+func (l *Layer) RotateTileCW(col, row int) {
+	gid := l.GetRawGID(col, row)
+	// The diagonal flip is applied before the horizontal and vertical flips.
+	h, v, d := !gid.IsVerticalFlip(), gid.IsHorizontalFlip(), !gid.IsDiagonalFlip()
+	l.SetRawGID(col, row, gid.WithFlip(h, v, d))
+}
+
+// FlipTileH flips the tile at a given coordinate horizontally, by updating its
+// flip flags.
+func (l *Layer) FlipTileH(col, row int) {
+	l.SetRawGID(col, row, l.GetRawGID(col, row)^FlagHorizontalFlip)
+}
+
+// FlipTileV flips the tile at a given coordinate vertically, by updating its
+// flip flags.
+func (l *Layer) FlipTileV(col, row int) {
+	l.SetRawGID(col, row, l.GetRawGID(col, row)^FlagVerticalFlip)
+}
@@ -0,0 +1,655 @@
+package tmx
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// chunkedMap is an infinite map with a single non-empty cell at (-31, -31),
+// which is stored in a chunk at (-32, -32).
+const chunkedMap = `
+<map version="1.2" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32" infinite="1">
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32" tilecount="4">
+  <image source="a.png" width="64" height="64"/>
+ </tileset>
+ <tileset firstgid="5" name="b" tilewidth="32" tileheight="32" tilecount="4">
+  <image source="b.png" width="64" height="64"/>
+ </tileset>
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">
+   <chunk x="-32" y="-32" width="2" height="2">0,0,0,6</chunk>
+  </data>
+ </layer>
+</map>`
+
+func TestLayerContentBoundsChunked(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	got, ok := l.ContentBounds()
+	if !ok {
+		t.Fatal("expected non-empty cells")
+	}
+	want := image.Rect(-31, -31, -30, -30)
+	if got != want {
+		t.Errorf("content bounds mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := l.OriginOffset(), image.Pt(-32, -32); got != want {
+		t.Errorf("origin offset mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := l.GetGID(-31, -31), 6; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestLayerContentBounds(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="4" height="3" tilewidth="32" tileheight="32">
+ <layer name="ground" width="4" height="3">
+  <data encoding="csv">
+0,0,0,0,
+0,1,2,0,
+0,0,3,0
+  </data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := m.Layers[0].ContentBounds()
+	if !ok {
+		t.Fatal("expected non-empty cells")
+	}
+	want := image.Rect(1, 1, 3, 3)
+	if got != want {
+		t.Errorf("content bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestLayerContentBoundsEmpty(t *testing.T) {
+	m := NewMap("orthogonal", 3, 2, 32, 32)
+	l := m.AddLayer("ground")
+	if _, ok := l.ContentBounds(); ok {
+		t.Error("expected no non-empty cells")
+	}
+	// Flip flags alone do not make a cell non-empty.
+	l.SetRawGID(1, 1, FlagHorizontalFlip)
+	if _, ok := l.ContentBounds(); ok {
+		t.Error("expected no non-empty cells for flipped empty cell")
+	}
+	l.SetRawGID(2, 0, MakeGID(1, false, true, false))
+	got, ok := l.ContentBounds()
+	if !ok {
+		t.Fatal("expected non-empty cells")
+	}
+	want := image.Rect(2, 0, 3, 1)
+	if got != want {
+		t.Errorf("content bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestSafeLayer(t *testing.T) {
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "1,2,3,2147483652")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl := m.Layers[0].Safe()
+	golden := []struct {
+		col, row int
+		want     int
+	}{
+		{col: 0, row: 0, want: 1},
+		// The flip flags are cleared.
+		{col: 1, row: 1, want: 4},
+		{col: -1, row: 0, want: 0},
+		{col: 0, row: -1, want: 0},
+		{col: 2, row: 0, want: 0},
+		{col: 0, row: 2, want: 0},
+	}
+	for _, g := range golden {
+		if got := sl.GID(g.col, g.row); got != g.want {
+			t.Errorf("(%d, %d): GID mismatch; expected %d, got %d", g.col, g.row, g.want, got)
+		}
+	}
+	if got, want := sl.RawGID(1, 1), MakeGID(4, true, false, false); got != want {
+		t.Errorf("raw GID mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestSafeLayerChunked(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl := m.Layers[0].Safe()
+	if got, want := sl.GID(-31, -31), 6; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+	// Outside of every chunk.
+	if got := sl.GID(0, 0); got != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", got)
+	}
+}
+
+func TestSafeLayerInvalid(t *testing.T) {
+	// Undecodable chunks don't panic.
+	m, err := NewFile(strings.NewReader(badChunkMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Layers[0].Safe().GID(0, 0); got != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", got)
+	}
+	// Undecodable layers don't panic.
+	src := layerMapSource(`encoding="csv"`, "1,2,3")
+	m, err = NewFile(strings.NewReader(src), WithLayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Layers[0].Safe().GID(0, 0); got != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", got)
+	}
+	var l *Layer
+	if got := l.Safe().GID(0, 0); got != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", got)
+	}
+}
+
+func TestLayerTileGrid(t *testing.T) {
+	// GIDs 1 (H), 2 (V), 3 (D) and 4 (HVD).
+	const data = "2147483649,1073741826,536870915,3758096388"
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := m.Layers[0].TileGrid()
+	want := [][]Cell{
+		{{GID: 1, H: true}, {GID: 3, D: true}},
+		{{GID: 2, V: true}, {GID: 4, H: true, V: true, D: true}},
+	}
+	if len(cells) != len(want) {
+		t.Fatalf("number of columns mismatch; expected %d, got %d", len(want), len(cells))
+	}
+	for col := range want {
+		if len(cells[col]) != len(want[col]) {
+			t.Fatalf("number of rows mismatch; expected %d, got %d", len(want[col]), len(cells[col]))
+		}
+		for row := range want[col] {
+			if got := cells[col][row]; got != want[col][row] {
+				t.Errorf("cell mismatch at (%d, %d); expected %+v, got %+v", col, row, want[col][row], got)
+			}
+		}
+	}
+}
+
+func TestLayerIDLocked(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <layer id="3" name="a" width="1" height="1" locked="1">
+  <data encoding="csv">1</data>
+ </layer>
+ <layer id="5" name="b" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		id     int
+		locked bool
+	}{
+		{id: 3, locked: true},
+		{id: 5, locked: false},
+	}
+	for i, g := range golden {
+		l := m.Layers[i]
+		if l.ID != g.id || l.Locked != g.locked {
+			t.Errorf("layer %q mismatch; expected ID %d and locked %v, got ID %d and locked %v", l.Name, g.id, g.locked, l.ID, l.Locked)
+		}
+	}
+}
+
+func TestLayerIndex(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="first"/>
+ <layer name="a" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+ <objectgroup name="second"/>
+ <objectgroup name="third"/>
+ <layer name="b" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices := make(map[string]int)
+	for _, l := range m.Layers {
+		indices[l.Name] = l.Index
+	}
+	for _, ol := range m.ObjectLayers {
+		indices[ol.Name] = ol.Index
+	}
+	want := map[string]int{"first": 0, "a": 1, "second": 2, "third": 3, "b": 4}
+	for name, index := range want {
+		if got := indices[name]; got != index {
+			t.Errorf("index of layer %q mismatch; expected %d, got %d", name, index, got)
+		}
+	}
+}
+
+func TestLayerRawGrid(t *testing.T) {
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "1,2147483650,3,4")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	grid := l.RawGrid()
+	if got, want := grid[1][0], MakeGID(2, true, false, false); got != want {
+		t.Errorf("raw GID mismatch; expected %d, got %d", want, got)
+	}
+	gid := MakeGID(7, false, true, true)
+	l.SetRawGID(0, 1, gid)
+	if got := l.GetRawGID(0, 1); got != gid {
+		t.Errorf("raw GID mismatch; expected %d, got %d", gid, got)
+	}
+	if got, want := l.GetGID(0, 1), 7; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+	// The grid is shared with the layer.
+	if got := grid[0][1]; got != gid {
+		t.Errorf("raw grid GID mismatch; expected %d, got %d", gid, got)
+	}
+}
+
+func TestLayerSetRawGIDChunked(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	if l.RawGrid() != nil {
+		t.Error("expected no raw grid for layer of infinite map")
+	}
+	gid := MakeGID(3, true, false, false)
+	l.SetRawGID(-32, -32, gid)
+	if got := l.GetRawGID(-32, -32); got != gid {
+		t.Errorf("raw GID mismatch; expected %d, got %d", gid, got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for coordinate outside of the chunks")
+		}
+	}()
+	l.SetRawGID(0, 0, gid)
+}
+
+func TestLayerASCII(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="3" height="2">
+  <data encoding="csv">1,0,12,0,2147483650,0</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1 . 12\n. 2 .\n"
+	if got := m.Layers[0].ASCII(); got != want {
+		t.Errorf("ASCII mismatch; expected %q, got %q", want, got)
+	}
+	// Layers without cells.
+	m = NewMap("orthogonal", 0, 0, 32, 32)
+	if got := m.AddLayer("empty").ASCII(); got != "" {
+		t.Errorf("ASCII mismatch; expected empty string, got %q", got)
+	}
+}
+
+func TestLayerIndexImage(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="3" height="2">
+  <data encoding="csv">1,0,2147483650,70000,65535,3</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := m.Layers[0].IndexImage()
+	if got, want := img.Bounds(), image.Rect(0, 0, 3, 2); got != want {
+		t.Fatalf("image bounds mismatch; expected %v, got %v", want, got)
+	}
+	// The flip flags are cleared, and GIDs above 65535 are saturated.
+	want := [][]uint16{
+		{1, 0, 2},
+		{65535, 65535, 3},
+	}
+	for row := range want {
+		for col, gid := range want[row] {
+			if got := img.Gray16At(col, row).Y; got != gid {
+				t.Errorf("pixel mismatch at (%d, %d); expected %d, got %d", col, row, gid, got)
+			}
+		}
+	}
+}
+
+func TestLayerMemUsage(t *testing.T) {
+	header := int(unsafe.Sizeof([]GID(nil)))
+	size := int(unsafe.Sizeof(GID(0)))
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="32" tileheight="32">
+ <layer name="a" width="3" height="2">
+  <data encoding="csv">1,2,3,4,5,6</data>
+ </layer>
+ <layer name="b" width="3" height="2">
+  <data encoding="csv">0,0,0,0,0,0</data>
+ </layer>
+ <objectgroup name="objects"/>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The grid of 3 columns of 2 rows and its slice headers.
+	want := header + 3*header + 3*2*size
+	for i := range m.Layers {
+		if got := m.Layers[i].MemUsage(); got != want {
+			t.Errorf("layer %q: memory usage mismatch; expected %d, got %d", m.Layers[i].Name, want, got)
+		}
+	}
+	if got := m.MemUsage(); got != 2*want {
+		t.Errorf("map memory usage mismatch; expected %d, got %d", 2*want, got)
+	}
+	var l Layer
+	if got := l.MemUsage(); got != 0 {
+		t.Errorf("memory usage of layer without data mismatch; expected 0, got %d", got)
+	}
+}
+
+func TestLayerMemUsageLazy(t *testing.T) {
+	header := int(unsafe.Sizeof([]GID(nil)))
+	size := int(unsafe.Sizeof(GID(0)))
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="32" tileheight="32">
+ <layer name="a" width="3" height="2">
+  <data encoding="csv">1,2,3,4,5,6</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src), WithLayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	// Layers which have not yet been decoded use no memory.
+	if got := l.MemUsage(); got != 0 {
+		t.Errorf("memory usage before decoding mismatch; expected 0, got %d", got)
+	}
+	if err := l.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	want := header + 3*header + 3*2*size
+	if got := l.MemUsage(); got != want {
+		t.Errorf("memory usage after decoding mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestLayerMemUsageChunked(t *testing.T) {
+	header := int(unsafe.Sizeof([]GID(nil)))
+	size := int(unsafe.Sizeof(GID(0)))
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The 2x2 chunk is decoded to determine its memory usage.
+	want := header + 2*header + 2*2*size
+	if got := m.Layers[0].MemUsage(); got != want {
+		t.Errorf("memory usage mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestLayerGetGIDAt(t *testing.T) {
+	// The GID at column 1 and row 0 is horizontally flipped.
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "1,2147483650,3,4")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	for row := 0; row < 2; row++ {
+		for col := 0; col < 2; col++ {
+			p := image.Pt(col, row)
+			if got, want := l.GetGIDAt(p), l.GetGID(col, row); got != want {
+				t.Errorf("GID mismatch at %v; expected %d, got %d", p, want, got)
+			}
+			if got, want := l.GetRawGIDAt(p), l.GetRawGID(col, row); got != want {
+				t.Errorf("raw GID mismatch at %v; expected %d, got %d", p, want, got)
+			}
+		}
+	}
+	p := image.Pt(1, 0)
+	if got := l.GetGIDAt(p); got != 2 {
+		t.Errorf("GID mismatch at %v; expected 2, got %d", p, got)
+	}
+	if got := l.GetRawGIDAt(p); got != MakeGID(2, true, false, false) {
+		t.Errorf("raw GID mismatch at %v; expected %d, got %d", p, MakeGID(2, true, false, false), got)
+	}
+}
+
+func TestLayerConnectedRegions(t *testing.T) {
+	// The GID at column 2 and row 2 is horizontally flipped.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="4" height="3" tilewidth="32" tileheight="32">
+ <layer name="ground" width="4" height="3">
+  <data encoding="csv">
+1,1,0,2,
+0,4,0,2,
+3,0,2147483650,2
+  </data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		sameGID bool
+		want    [][]image.Point
+	}{
+		{
+			sameGID: false,
+			want: [][]image.Point{
+				{{0, 0}, {1, 0}, {1, 1}},
+				{{3, 0}, {3, 1}, {2, 2}, {3, 2}},
+				{{0, 2}},
+			},
+		},
+		// Flip flags are ignored when comparing GIDs.
+		{
+			sameGID: true,
+			want: [][]image.Point{
+				{{0, 0}, {1, 0}},
+				{{3, 0}, {3, 1}, {2, 2}, {3, 2}},
+				{{1, 1}},
+				{{0, 2}},
+			},
+		},
+	}
+	for _, g := range golden {
+		got := m.Layers[0].ConnectedRegions(g.sameGID)
+		if len(got) != len(g.want) {
+			t.Errorf("sameGID=%v: number of regions mismatch; expected %d, got %d", g.sameGID, len(g.want), len(got))
+			continue
+		}
+		for i, want := range g.want {
+			if !equalRegions(got[i], want) {
+				t.Errorf("sameGID=%v: region %d mismatch; expected %v, got %v", g.sameGID, i, want, got[i])
+			}
+		}
+	}
+	// Layers of infinite maps have no regions.
+	m, err = NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Layers[0].ConnectedRegions(false); len(got) != 0 {
+		t.Errorf("number of regions mismatch; expected 0, got %d", len(got))
+	}
+}
+
+// equalRegions reports whether the regions a and b contain the same cells,
+// regardless of order.
+func equalRegions(a, b []image.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	cells := make(map[image.Point]bool)
+	for _, p := range a {
+		cells[p] = true
+	}
+	for _, p := range b {
+		if !cells[p] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLayerInfiniteWithoutChunks(t *testing.T) {
+	// Infinite maps may have a width and height of 0, and layers without
+	// chunks.
+	const src = `
+<map version="1.2" orientation="orthogonal" width="0" height="0" tilewidth="32" tileheight="32" infinite="1">
+ <layer name="empty" width="0" height="0">
+  <data encoding="csv"></data>
+ </layer>
+ <layer name="ground" width="0" height="0">
+  <data encoding="csv">
+   <chunk x="-16" y="0" width="2" height="1">1,2</chunk>
+  </data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := &m.Layers[0]
+	for _, p := range []image.Point{{0, 0}, {-16, 0}, {100, -100}} {
+		if gid := empty.GetGIDAt(p); gid != 0 {
+			t.Errorf("GID mismatch at %v; expected 0, got %d", p, gid)
+		}
+		if gid := empty.Safe().RawGID(p.X, p.Y); gid != 0 {
+			t.Errorf("safe GID mismatch at %v; expected 0, got %d", p, gid)
+		}
+	}
+	ground := &m.Layers[1]
+	if gid := ground.GetGID(-15, 0); gid != 2 {
+		t.Errorf("GID mismatch; expected 2, got %d", gid)
+	}
+	if gid := ground.GetGID(0, 0); gid != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", gid)
+	}
+}
+
+func TestLayerGIDsRowMajor(t *testing.T) {
+	// The GID at column 2 and row 0 is vertically flipped.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="3" height="2">
+  <data encoding="csv">1,2,1073741827,4,5,0</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	want := []int{1, 2, 3, 4, 5, 0}
+	got := l.GIDsRowMajor()
+	if len(got) != len(want) {
+		t.Fatalf("number of GIDs mismatch; expected %d, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GID %d mismatch; expected %d, got %d", i, want[i], got[i])
+		}
+	}
+	raw := l.RawGIDsRowMajor()
+	if len(raw) != len(want) {
+		t.Fatalf("number of raw GIDs mismatch; expected %d, got %d", len(want), len(raw))
+	}
+	if gid := MakeGID(3, false, true, false); raw[2] != gid {
+		t.Errorf("raw GID mismatch; expected %d, got %d", gid, raw[2])
+	}
+	// Layers of infinite maps have no cell grid.
+	m, err = NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Layers[0].GIDsRowMajor(); got != nil {
+		t.Errorf("expected no GIDs, got %v", got)
+	}
+	if got := m.Layers[0].RawGIDsRowMajor(); got != nil {
+		t.Errorf("expected no raw GIDs, got %v", got)
+	}
+}
+
+func TestLayerRotateTileCW(t *testing.T) {
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "5,0,0,0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	// Flip flags of a tile rotated by 90, 180, 270 and 360 degrees clockwise.
+	golden := []struct {
+		h, v, d bool
+	}{
+		{h: true, d: true},
+		{h: true, v: true},
+		{v: true, d: true},
+		{},
+	}
+	for i, g := range golden {
+		l.RotateTileCW(0, 0)
+		gid := l.GetRawGID(0, 0)
+		if want := MakeGID(5, g.h, g.v, g.d); gid != want {
+			t.Errorf("rotation %d: GID mismatch; expected %#x, got %#x", i+1, uint32(want), uint32(gid))
+		}
+	}
+	// Cells which have not been rotated are left unchanged.
+	if gid := l.GetRawGID(1, 0); gid != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", gid)
+	}
+}
+
+func TestLayerFlipTile(t *testing.T) {
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "5,0,0,0")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+	l.FlipTileH(0, 0)
+	if gid, want := l.GetRawGID(0, 0), MakeGID(5, true, false, false); gid != want {
+		t.Errorf("GID mismatch; expected %#x, got %#x", uint32(want), uint32(gid))
+	}
+	l.FlipTileV(0, 0)
+	if gid, want := l.GetRawGID(0, 0), MakeGID(5, true, true, false); gid != want {
+		t.Errorf("GID mismatch; expected %#x, got %#x", uint32(want), uint32(gid))
+	}
+	// Flipping twice restores the original orientation.
+	l.FlipTileH(0, 0)
+	l.FlipTileV(0, 0)
+	if gid := l.GetRawGID(0, 0); gid != 5 {
+		t.Errorf("GID mismatch; expected 5, got %#x", uint32(gid))
+	}
+}
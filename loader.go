@@ -0,0 +1,86 @@
+package tmx
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// A Loader loads tmx files, caching the external tilesets they refer to. A
+// cached tileset is reloaded once the modification time of its TSX file
+// changes, so long-running programs pick up edits to tilesets. It is safe to
+// use a Loader concurrently.
+//
+// Note: Maps loaded by the same Loader share the tile information, properties
+// and Wang sets of cached tilesets, which must therefore be treated as
+// read-only.
+type Loader struct {
+	// opts contains the parsing options used for every tmx file.
+	opts []Option
+	// mu protects cache.
+	mu sync.Mutex
+	// cache maps from TSX file paths to cached tilesets.
+	cache map[string]cachedTSX
+	// modTime returns the modification time of the named file.
+	modTime func(name string) (time.Time, error)
+}
+
+// cachedTSX is a tileset cached by a Loader.
+type cachedTSX struct {
+	// The modification time of the TSX file when it was read.
+	modTime time.Time
+	// The parsed tileset.
+	ts *Tileset
+}
+
+// NewLoader returns a new Loader, which parses tmx files using the provided
+// options.
+func NewLoader(opts ...Option) *Loader {
+	return &Loader{
+		opts:    opts,
+		cache:   make(map[string]cachedTSX),
+		modTime: fileModTime,
+	}
+}
+
+// Open reads the provided tmx file and returns a parsed Map, based on the TMX
+// file format. External tilesets are loaded from the cache of the loader, or
+// from TSX files located relative to the tmx file.
+func (l *Loader) Open(tmxPath string) (*Map, error) {
+	conf := newConfig(l.opts)
+	conf.readTSX = l.readTSX
+	return openFile(tmxPath, conf)
+}
+
+// readTSX returns a copy of the tileset of the provided TSX file, which is read
+// unless it is cached and has not been modified since.
+func (l *Loader) readTSX(tsxPath string) (*Tileset, error) {
+	modTime, err := l.modTime(tsxPath)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	entry, ok := l.cache[tsxPath]
+	l.mu.Unlock()
+	if !ok || !entry.modTime.Equal(modTime) {
+		ts, err := readTSX(tsxPath)
+		if err != nil {
+			return nil, err
+		}
+		entry = cachedTSX{modTime: modTime, ts: ts}
+		l.mu.Lock()
+		l.cache[tsxPath] = entry
+		l.mu.Unlock()
+	}
+	ts := *entry.ts
+	return &ts, nil
+}
+
+// fileModTime returns the modification time of the named file.
+func fileModTime(name string) (time.Time, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
@@ -0,0 +1,102 @@
+package tmx
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoader(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="tiles/ext.tsx"/>
+</map>`,
+		"tiles/ext.tsx": `
+<tileset name="v1" tilewidth="32" tileheight="32" tilecount="4">
+ <image source="ext.png" width="64" height="64"/>
+</tileset>`,
+	})
+	tmxPath := filepath.Join(dir, "map.tmx")
+	tsxPath := filepath.Join(dir, "tiles", "ext.tsx")
+	l := NewLoader()
+	// The modification time of the TSX file is controlled by the test.
+	modTime := time.Unix(1000, 0)
+	l.modTime = func(name string) (time.Time, error) {
+		return modTime, nil
+	}
+	open := func() *Tileset {
+		m, err := l.Open(tmxPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts := &m.Tilesets[0]
+		// Image sources are adjusted once per map, not once per load.
+		if got, want := ts.Image.Source, "tiles/ext.png"; got != want {
+			t.Errorf("image source mismatch; expected %q, got %q", want, got)
+		}
+		return ts
+	}
+	ts := open()
+	if ts.Name != "v1" {
+		t.Fatalf("tileset name mismatch; expected %q, got %q", "v1", ts.Name)
+	}
+	// The maps don't share the tileset itself.
+	ts.Name = "modified"
+	v2 := `
+<tileset name="v2" tilewidth="32" tileheight="32" tilecount="4">
+ <image source="ext.png" width="64" height="64"/>
+</tileset>`
+	if err := ioutil.WriteFile(tsxPath, []byte(v2), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The cached tileset is used until the modification time changes.
+	if ts := open(); ts.Name != "v1" {
+		t.Errorf("tileset name mismatch; expected cached %q, got %q", "v1", ts.Name)
+	}
+	modTime = modTime.Add(time.Second)
+	if ts := open(); ts.Name != "v2" {
+		t.Errorf("tileset name mismatch; expected reloaded %q, got %q", "v2", ts.Name)
+	}
+}
+
+func TestLoaderConcurrent(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="ext.tsx"/>
+</map>`,
+		"ext.tsx": `<tileset name="ext" tilewidth="32" tileheight="32" tilecount="4"/>`,
+	})
+	l := NewLoader()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := l.Open(filepath.Join(dir, "map.tmx"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if name := m.Tilesets[0].Name; name != "ext" {
+				t.Errorf("tileset name mismatch; expected %q, got %q", "ext", name)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoaderMissingTileset(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="missing.tsx"/>
+</map>`,
+	})
+	if _, err := NewLoader().Open(filepath.Join(dir, "map.tmx")); err == nil {
+		t.Error("expected error for missing TSX file")
+	}
+}
@@ -0,0 +1,312 @@
+package tmx
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NewMap returns a new empty map of the given orientation, with cols columns
+// and rows rows of tiles, each tileWidth by tileHeight pixels. Tilesets and
+// layers are added by the caller (see AddLayer).
+func NewMap(orientation string, cols, rows, tileWidth, tileHeight int) *Map {
+	return &Map{
+		Version:      "1.0",
+		Orientation:  orientation,
+		RenderOrder:  "right-down",
+		Width:        cols,
+		Height:       rows,
+		TileWidth:    tileWidth,
+		TileHeight:   tileHeight,
+		NextLayerID:  1,
+		NextObjectID: 1,
+	}
+}
+
+// AddLayer appends a visible and opaque tile layer with the given name to the
+// map, and returns a pointer to the appended layer. Every cell of the layer is
+// empty (GID 0). The layer is assigned the next available layer ID of the map,
+// and NextLayerID is incremented.
+//
+// Note: The returned pointer is invalidated by subsequent calls to AddLayer.
+func (m *Map) AddLayer(name string) *Layer {
+	id, index := max(m.NextLayerID, 1), 0
+	for _, l := range m.Layers {
+		id = max(id, l.ID+1)
+		index = max(index, l.Index+1)
+	}
+	for _, ol := range m.ObjectLayers {
+		id = max(id, ol.ID+1)
+		index = max(index, ol.Index+1)
+	}
+	m.NextLayerID = id + 1
+	data := &Data{Encoding: "csv", infinite: m.Infinite}
+	if !m.Infinite {
+		data.alloc(m.Width, m.Height)
+	}
+	m.Layers = append(m.Layers, Layer{
+		ID:      id,
+		Name:    name,
+		Width:   m.Width,
+		Height:  m.Height,
+		Visible: true,
+		Opacity: 1,
+		Data:    data,
+		Index:   index,
+	})
+	return &m.Layers[len(m.Layers)-1]
+}
+
+// Resize changes the dimensions of the map to newCols columns and newRows rows.
+// The GIDs of the overlapping region are preserved in every tile layer, and the
+// cells added by growing the map are empty (GID 0). An error is returned if the
+// dimensions are invalid or too large, or if the map is infinite, as the
+// dimensions of infinite maps are determined by their chunks.
+//
+// Note: The RawData of the layers is not updated to reflect the new dimensions.
+func (m *Map) Resize(newCols, newRows int) error {
+	err := checkDims(newCols, newRows)
+	if err != nil {
+		return err
+	}
+	if m.Infinite {
+		return fmt.Errorf("Resize: unable to resize infinite map.")
+	}
+	for i := range m.Layers {
+		l := &m.Layers[i]
+		if l.Data == nil {
+			l.Data = new(Data)
+		}
+		l.decoded()
+		gids := make([][]GID, newCols)
+		for col := range gids {
+			gids[col] = make([]GID, newRows)
+			if col < len(l.Data.gids) {
+				copy(gids[col], l.Data.gids[col])
+			}
+		}
+		l.Data.gids = gids
+		l.Width, l.Height = newCols, newRows
+	}
+	m.Width = newCols
+	m.Height = newRows
+	return nil
+}
+
+// WriteLayerCSV writes the GIDs of the named layer to w as comma-separated
+// values, after clearing the flip flags. Each row of the layer is written on a
+// separate line.
+func (m *Map) WriteLayerCSV(layerName string, w io.Writer) error {
+	l := m.layer(layerName)
+	if l == nil {
+		return fmt.Errorf("WriteLayerCSV: unable to locate layer '%s'.", layerName)
+	}
+	bw := bufio.NewWriter(w)
+	for row := 0; row < l.Height; row++ {
+		for col := 0; col < l.Width; col++ {
+			if col > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(strconv.Itoa(l.GetGID(col, row)))
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// layer returns the named tile layer, or nil if no such layer exists.
+func (m *Map) layer(name string) *Layer {
+	for i := range m.Layers {
+		if m.Layers[i].Name == name {
+			return &m.Layers[i]
+		}
+	}
+	return nil
+}
+
+// RequireVersion returns an error if the TMX format version of the map is not
+// within the inclusive range from min to max. An empty min or max leaves the
+// range unbounded in that direction. Versions are compared numerically by
+// component, so "1.10" is newer than "1.9". An error is returned if the map has
+// no version.
+func (m *Map) RequireVersion(min, max string) error {
+	if (min != "" || max != "") && m.Version == "" {
+		return fmt.Errorf("RequireVersion: map has no version.")
+	}
+	if min != "" {
+		cmp, err := compareVersions(m.Version, min)
+		if err != nil {
+			return err
+		}
+		if cmp < 0 {
+			return fmt.Errorf("RequireVersion: map version %s is older than %s.", m.Version, min)
+		}
+	}
+	if max != "" {
+		cmp, err := compareVersions(m.Version, max)
+		if err != nil {
+			return err
+		}
+		if cmp > 0 {
+			return fmt.Errorf("RequireVersion: map version %s is newer than %s.", m.Version, max)
+		}
+	}
+	return nil
+}
+
+// compareVersions compares the dot-separated versions a and b, and returns -1
+// if a is older than b, 0 if they are equal and +1 if a is newer than b.
+// Missing components are treated as 0, so "1" equals "1.0".
+func compareVersions(a, b string) (int, error) {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, err := versionComponent(as, i)
+		if err != nil {
+			return 0, err
+		}
+		y, err := versionComponent(bs, i)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// versionComponent returns the numeric value of the i:th version component, or
+// 0 if the version has fewer components.
+func versionComponent(components []string, i int) (int, error) {
+	if i >= len(components) {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(components[i])
+	if err != nil {
+		return 0, fmt.Errorf("versionComponent: invalid version component '%s'.", components[i])
+	}
+	return n, nil
+}
+
+// RemapGIDs replaces the global tile IDs of all tile layers and tile objects of
+// the map according to the given mapping from old to new global tile IDs. The
+// flip flags are preserved, and GIDs not present in the mapping are left
+// unchanged.
+func (m *Map) RemapGIDs(mapping map[int]int) {
+	remap := func(gid GID) GID {
+		if id, ok := mapping[gid.GlobalTileID()]; ok {
+			return GID(id) | gid&FlagFlip
+		}
+		return gid
+	}
+	remapGrid := func(gids [][]GID) {
+		for col := range gids {
+			for row := range gids[col] {
+				gids[col][row] = remap(gids[col][row])
+			}
+		}
+	}
+	for i := range m.Layers {
+		data := m.Layers[i].Data
+		if data == nil {
+			continue
+		}
+		m.Layers[i].decoded()
+		remapGrid(data.gids)
+		for j := range data.Chunks {
+			gids, err := data.Chunks[j].gids()
+			if err != nil {
+				panic(fmt.Sprintf("tmx.Map.RemapGIDs: unable to decode chunk of layer '%s'; %v", m.Layers[i].Name, err))
+			}
+			remapGrid(gids)
+		}
+	}
+	for i := range m.ObjectLayers {
+		ol := &m.ObjectLayers[i]
+		for j := range ol.Objects {
+			o := &ol.Objects[j]
+			if o.GID != 0 {
+				o.GID = remap(o.GID)
+			}
+		}
+	}
+}
+
+// VisibleCells returns the inclusive range of cells which overlap the given
+// viewport in pixels, clamped to the bounds of the map. If the viewport doesn't
+// overlap the map, maxCol and maxRow are less than minCol and minRow.
+//
+// Note: Only orthogonal maps are supported at the moment.
+func (m *Map) VisibleCells(viewport image.Rectangle) (minCol, minRow, maxCol, maxRow int) {
+	mapRect := image.Rect(0, 0, m.Width*m.TileWidth, m.Height*m.TileHeight)
+	r := viewport.Intersect(mapRect)
+	if r.Empty() {
+		return 0, 0, -1, -1
+	}
+	minCol = r.Min.X / m.TileWidth
+	minRow = r.Min.Y / m.TileHeight
+	maxCol = (r.Max.X - 1) / m.TileWidth
+	maxRow = (r.Max.Y - 1) / m.TileHeight
+	return minCol, minRow, maxCol, maxRow
+}
+
+// UnsupportedFeatures returns the names of the features used by the map which
+// are not fully supported by the renderer of the mapview package, or nil if the
+// map uses no such features.
+func (m *Map) UnsupportedFeatures() []string {
+	var features []string
+	if !m.IsOrthogonal() && !m.IsIsometric() && !m.IsStaggered() {
+		features = append(features, fmt.Sprintf("%s orientation", m.Orientation))
+	}
+	if m.Infinite {
+		features = append(features, "infinite map")
+	}
+	if len(m.ImageLayers) > 0 {
+		features = append(features, "image layers")
+	}
+	animated, collection := false, false
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		if ts.isCollection() {
+			collection = true
+		}
+		for _, t := range ts.TilesInfo {
+			if len(t.Animation.Frames) > 0 {
+				animated = true
+			}
+		}
+	}
+	if animated {
+		features = append(features, "animated tiles")
+	}
+	if collection {
+		features = append(features, "image collection tilesets")
+	}
+	return features
+}
+
+// IsOrthogonal returns true if the map has orthogonal orientation.
+func (m *Map) IsOrthogonal() bool {
+	return m.Orientation == "orthogonal"
+}
+
+// IsIsometric returns true if the map has isometric orientation.
+func (m *Map) IsIsometric() bool {
+	return m.Orientation == "isometric"
+}
+
+// IsStaggered returns true if the map has staggered (isometric) orientation.
+func (m *Map) IsStaggered() bool {
+	return m.Orientation == "staggered"
+}
+
+// IsHexagonal returns true if the map has hexagonal orientation.
+func (m *Map) IsHexagonal() bool {
+	return m.Orientation == "hexagonal"
+}
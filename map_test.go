@@ -0,0 +1,391 @@
+package tmx
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestMapResize(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">1,2,3,4</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Resize(4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 4 || m.Height != 4 {
+		t.Fatalf("map dimensions mismatch; expected 4x4, got %dx%d", m.Width, m.Height)
+	}
+	want := [][]int{
+		{1, 2, 0, 0},
+		{3, 4, 0, 0},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+	l := &m.Layers[0]
+	for row := range want {
+		for col, gid := range want[row] {
+			if got := l.GetGID(col, row); got != gid {
+				t.Errorf("GID mismatch at (%d, %d); expected %d, got %d", col, row, gid, got)
+			}
+		}
+	}
+}
+
+func TestMapResizeInvalid(t *testing.T) {
+	m := NewMap("orthogonal", 2, 2, 32, 32)
+	m.AddLayer("ground")
+	if err := m.Resize(-1, 2); err == nil {
+		t.Error("expected error for negative dimensions")
+	}
+	m.Infinite = true
+	if err := m.Resize(4, 4); err == nil {
+		t.Error("expected error for infinite map")
+	}
+	if m.Width != 2 || m.Height != 2 {
+		t.Errorf("map dimensions changed; expected 2x2, got %dx%d", m.Width, m.Height)
+	}
+}
+
+func TestMapRequireVersion(t *testing.T) {
+	golden := []struct {
+		version  string
+		min, max string
+		ok       bool
+	}{
+		{version: "1.0", min: "1.0", max: "1.0", ok: true},
+		{version: "1.10", min: "1.9", ok: true},
+		{version: "1.9", min: "1.10", ok: false},
+		{version: "1.2", max: "1.10", ok: true},
+		{version: "1.10", max: "1.9", ok: false},
+		{version: "1.4.1", min: "1.4", max: "1.4.2", ok: true},
+		{version: "1.0", ok: true},
+		{version: "", ok: true},
+	}
+	for _, g := range golden {
+		m := &Map{Version: g.version}
+		err := m.RequireVersion(g.min, g.max)
+		if ok := err == nil; ok != g.ok {
+			t.Errorf("version %q in range [%q, %q]; expected ok=%t, got error %v", g.version, g.min, g.max, g.ok, err)
+		}
+	}
+}
+
+func TestMapRequireVersionMissing(t *testing.T) {
+	m := &Map{}
+	err := m.RequireVersion("1.0", "")
+	if err == nil {
+		t.Fatal("expected error for map without version")
+	}
+	if got, want := err.Error(), "RequireVersion: map has no version."; got != want {
+		t.Errorf("error mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestMapWriteLayerCSV(t *testing.T) {
+	// The layer is smaller than the map.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="3" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">1,2</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	err = m.WriteLayerCSV("ground", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1,2\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestMapStaggered(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="staggered" renderorder="left-up" width="2" height="2" tilewidth="32" tileheight="16" staggeraxis="x" staggerindex="even">
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsStaggered() || m.IsOrthogonal() || m.IsIsometric() {
+		t.Errorf("orientation mismatch; expected staggered, got %q", m.Orientation)
+	}
+	if m.RenderOrder != "left-up" || m.StaggerAxis != "x" || m.StaggerIndex != "even" {
+		t.Errorf("attribute mismatch; got render order %q, stagger axis %q and stagger index %q", m.RenderOrder, m.StaggerAxis, m.StaggerIndex)
+	}
+}
+
+func TestMapWriteLayerCSVRoundTrip(t *testing.T) {
+	m, err := Open("testdata/test_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range m.Layers {
+		var buf strings.Builder
+		if err := m.WriteLayerCSV(l.Name, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Count(buf.String(), "\n"), l.Height; got != want {
+			t.Errorf("layer %q: number of lines mismatch; expected %d, got %d", l.Name, want, got)
+		}
+		// The output is valid csv layer data of the same layer.
+		data := &Data{Encoding: "csv", RawData: buf.String()}
+		if err := data.decode(l.Width, l.Height, newConfig(nil)); err != nil {
+			t.Fatalf("layer %q: %v", l.Name, err)
+		}
+		for col := 0; col < l.Width; col++ {
+			for row := 0; row < l.Height; row++ {
+				if got, want := data.gids[col][row].GlobalTileID(), l.GetGID(col, row); got != want {
+					t.Fatalf("layer %q: GID mismatch at (%d, %d); expected %d, got %d", l.Name, col, row, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestMapWriteLayerCSVFlip(t *testing.T) {
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "2147483649,2,3,1073741828")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := m.WriteLayerCSV("ground", &buf); err != nil {
+		t.Fatal(err)
+	}
+	// The flip flags are cleared.
+	want := "1,2\n3,4\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV mismatch; expected %q, got %q", want, got)
+	}
+	if err := m.WriteLayerCSV("missing", &buf); err == nil {
+		t.Error("expected error for missing layer")
+	}
+}
+
+func TestMapRemapGIDs(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">1,2,2147483649,0</data>
+ </layer>
+ <objectgroup name="objects">
+  <object id="1" gid="1073741825" x="0" y="32"/>
+  <object id="2" x="0" y="32"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RemapGIDs(map[int]int{1: 10})
+	// The flip flags are preserved, and unmapped GIDs are left unchanged.
+	l := &m.Layers[0]
+	want := []GID{10, 2, MakeGID(10, true, false, false), 0}
+	got := l.RawGIDsRowMajor()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("raw GID %d mismatch; expected %d, got %d", i, want[i], got[i])
+		}
+	}
+	objects := m.ObjectLayers[0].Objects
+	if got, want := objects[0].GID, MakeGID(10, false, true, false); got != want {
+		t.Errorf("object GID mismatch; expected %d, got %d", want, got)
+	}
+	// Objects without a GID are not tile objects.
+	if got := objects[1].GID; got != 0 {
+		t.Errorf("object GID mismatch; expected 0, got %d", got)
+	}
+}
+
+func TestMapRemapGIDsChunked(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RemapGIDs(map[int]int{6: 2})
+	if got, want := m.Layers[0].GetGID(-31, -31), 2; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestMapVisibleCells(t *testing.T) {
+	// 4x3 map of 32x16 tiles, 128x48 pixels.
+	m := NewMap("orthogonal", 4, 3, 32, 16)
+	golden := []struct {
+		viewport                       image.Rectangle
+		minCol, minRow, maxCol, maxRow int
+	}{
+		{viewport: image.Rect(0, 0, 128, 48), minCol: 0, minRow: 0, maxCol: 3, maxRow: 2},
+		{viewport: image.Rect(0, 0, 32, 16), minCol: 0, minRow: 0, maxCol: 0, maxRow: 0},
+		{viewport: image.Rect(31, 15, 33, 17), minCol: 0, minRow: 0, maxCol: 1, maxRow: 1},
+		// Clamped to the bounds of the map.
+		{viewport: image.Rect(-100, -100, 40, 20), minCol: 0, minRow: 0, maxCol: 1, maxRow: 1},
+		{viewport: image.Rect(100, 40, 1000, 1000), minCol: 3, minRow: 2, maxCol: 3, maxRow: 2},
+	}
+	for _, g := range golden {
+		minCol, minRow, maxCol, maxRow := m.VisibleCells(g.viewport)
+		if minCol != g.minCol || minRow != g.minRow || maxCol != g.maxCol || maxRow != g.maxRow {
+			t.Errorf("%v: visible cells mismatch; expected (%d, %d)-(%d, %d), got (%d, %d)-(%d, %d)", g.viewport, g.minCol, g.minRow, g.maxCol, g.maxRow, minCol, minRow, maxCol, maxRow)
+		}
+	}
+	// Viewports outside of the map.
+	for _, viewport := range []image.Rectangle{image.Rect(128, 0, 200, 48), image.Rect(-10, -10, 0, 0), {}} {
+		minCol, minRow, maxCol, maxRow := m.VisibleCells(viewport)
+		if maxCol >= minCol || maxRow >= minRow {
+			t.Errorf("%v: expected empty range, got (%d, %d)-(%d, %d)", viewport, minCol, minRow, maxCol, maxRow)
+		}
+	}
+}
+
+func TestMapOrientation(t *testing.T) {
+	golden := []struct {
+		orientation                                 string
+		orthogonal, isometric, staggered, hexagonal bool
+	}{
+		{orientation: "orthogonal", orthogonal: true},
+		{orientation: "isometric", isometric: true},
+		{orientation: "staggered", staggered: true},
+		{orientation: "hexagonal", hexagonal: true},
+		{orientation: "unknown"},
+	}
+	for _, g := range golden {
+		m := NewMap(g.orientation, 1, 1, 32, 32)
+		if m.IsOrthogonal() != g.orthogonal || m.IsIsometric() != g.isometric || m.IsStaggered() != g.staggered || m.IsHexagonal() != g.hexagonal {
+			t.Errorf("%s: orientation predicates mismatch", g.orientation)
+		}
+	}
+}
+
+func TestNewMap(t *testing.T) {
+	m := NewMap("orthogonal", 3, 2, 16, 8)
+	if m.Orientation != "orthogonal" || m.Width != 3 || m.Height != 2 || m.TileWidth != 16 || m.TileHeight != 8 {
+		t.Errorf("map mismatch; expected orthogonal 3x2 map of 16x8 tiles, got %s %dx%d map of %dx%d tiles", m.Orientation, m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+	if m.NextLayerID != 1 || m.NextObjectID != 1 {
+		t.Errorf("next IDs mismatch; expected layer ID 1 and object ID 1, got layer ID %d and object ID %d", m.NextLayerID, m.NextObjectID)
+	}
+	a := m.AddLayer("a")
+	if a.ID != 1 || a.Index != 0 {
+		t.Errorf("layer %q: ID and index mismatch; expected 1 and 0, got %d and %d", a.Name, a.ID, a.Index)
+	}
+	if !a.Visible || a.Opacity != 1 || a.Width != 3 || a.Height != 2 {
+		t.Errorf("layer %q: expected visible and opaque 3x2 layer, got visible=%v, opacity=%v, %dx%d", a.Name, a.Visible, a.Opacity, a.Width, a.Height)
+	}
+	a.SetRawGID(2, 1, 5)
+	b := m.AddLayer("b")
+	if b.ID != 2 || b.Index != 1 {
+		t.Errorf("layer %q: ID and index mismatch; expected 2 and 1, got %d and %d", b.Name, b.ID, b.Index)
+	}
+	if m.NextLayerID != 3 {
+		t.Errorf("next layer ID mismatch; expected 3, got %d", m.NextLayerID)
+	}
+	checkGIDs(t, m, []int{0, 0, 0, 0, 0, 5})
+	// The map can be written and parsed again.
+	var buf strings.Builder
+	if err := m.WriteLayerCSV("a", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "0,0,0\n0,0,5\n"; got != want {
+		t.Errorf("CSV mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestMapAddLayer(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32" nextlayerid="8">
+ <layer id="2" name="ground" width="2" height="2">
+  <data encoding="csv">1,2,3,4</data>
+ </layer>
+ <objectgroup id="5" name="objects"/>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Layer IDs are not reused, even if below the next layer ID of the map.
+	l := m.AddLayer("top")
+	if l.ID != 8 || l.Index != 2 {
+		t.Errorf("ID and index mismatch; expected 8 and 2, got %d and %d", l.ID, l.Index)
+	}
+	if m.NextLayerID != 9 {
+		t.Errorf("next layer ID mismatch; expected 9, got %d", m.NextLayerID)
+	}
+	// Layers of infinite maps start out without chunks.
+	m = NewMap("orthogonal", 0, 0, 32, 32)
+	m.Infinite = true
+	l = m.AddLayer("ground")
+	if !l.Data.chunked() || len(l.Data.Chunks) != 0 {
+		t.Errorf("expected chunked layer without chunks, got %d chunks (chunked=%v)", len(l.Data.Chunks), l.Data.chunked())
+	}
+	if gid := l.GetGID(-20, 40); gid != 0 {
+		t.Errorf("GID mismatch; expected 0, got %d", gid)
+	}
+}
+
+func TestMapUnsupportedFeatures(t *testing.T) {
+	const plain = `
+<map version="1.0" orientation="staggered" width="1" height="1" tilewidth="32" tileheight="32" staggeraxis="y" staggerindex="odd">
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="4">
+  <image source="tiles.png" width="64" height="64"/>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.UnsupportedFeatures(); got != nil {
+		t.Errorf("expected no unsupported features, got %v", got)
+	}
+	const src = `
+<map version="1.2" orientation="hexagonal" width="1" height="1" tilewidth="32" tileheight="32" infinite="1">
+ <tileset firstgid="1" name="animated" tilewidth="32" tileheight="32" tilecount="4">
+  <image source="tiles.png" width="64" height="64"/>
+  <tile id="0">
+   <animation>
+    <frame tileid="0" duration="100"/>
+    <frame tileid="1" duration="100"/>
+   </animation>
+  </tile>
+ </tileset>
+ <tileset firstgid="5" name="collection" tilewidth="32" tileheight="32">
+  <tile id="0">
+   <image source="a.png" width="32" height="32"/>
+  </tile>
+ </tileset>
+ <group name="g">
+  <imagelayer name="sky" offsetx="4" offsety="-2">
+   <image source="sky.png" width="320" height="240"/>
+  </imagelayer>
+ </group>
+</map>`
+	m, err = NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"hexagonal orientation", "infinite map", "image layers", "animated tiles", "image collection tilesets"}
+	if got := m.UnsupportedFeatures(); !equalStrings(got, want) {
+		t.Errorf("unsupported features mismatch; expected %q, got %q", want, got)
+	}
+	// The image layers of group layers are moved to the map.
+	if len(m.ImageLayers) != 1 {
+		t.Fatalf("number of image layers mismatch; expected 1, got %d", len(m.ImageLayers))
+	}
+	il := m.ImageLayers[0]
+	if il.Name != "sky" || !il.Visible || il.Opacity != 1 || il.OffsetX != 4 || il.OffsetY != -2 || il.Image.Source != "sky.png" {
+		t.Errorf("image layer mismatch; got %+v", il)
+	}
+}
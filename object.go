@@ -0,0 +1,258 @@
+package tmx
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ObjectByID returns the object with the given ID, or nil if no such object
+// exists.
+func (m *Map) ObjectByID(id int) *Object {
+	for i := range m.ObjectLayers {
+		ol := &m.ObjectLayers[i]
+		for j := range ol.Objects {
+			if ol.Objects[j].ID == id {
+				return &ol.Objects[j]
+			}
+		}
+	}
+	return nil
+}
+
+// EachObject invokes fn for each object of the map, together with the name of
+// its object layer. The object layers are visited in document order, including
+// the object layers of group layers, and fn may modify the objects.
+func (m *Map) EachObject(fn func(layerName string, o *Object)) {
+	for i := range m.ObjectLayers {
+		ol := &m.ObjectLayers[i]
+		for j := range ol.Objects {
+			fn(ol.Name, &ol.Objects[j])
+		}
+	}
+}
+
+// ObjectGIDs returns the unique global tile IDs referenced by the tile objects
+// of the map, in ascending order. The flip flags are cleared, which makes it
+// possible to preload exactly the tile images needed by the objects.
+func (m *Map) ObjectGIDs() []int {
+	used := make(map[int]bool)
+	var gids []int
+	m.EachObject(func(_ string, o *Object) {
+		if o.GID == 0 {
+			return
+		}
+		gid := o.GID.GlobalTileID()
+		if !used[gid] {
+			used[gid] = true
+			gids = append(gids, gid)
+		}
+	})
+	sort.Ints(gids)
+	return gids
+}
+
+// Bounds returns the axis-aligned bounding rectangle of the object in pixels,
+// ignoring its rotation. Fractional coordinates are expanded to the smallest
+// rectangle of whole pixels which contains the object.
+func (o Object) Bounds() image.Rectangle {
+	return image.Rect(floor(o.X), floor(o.Y), ceil(o.X+o.Width), ceil(o.Y+o.Height))
+}
+
+// RotatedBounds returns the axis-aligned bounding rectangle in pixels of the
+// object after it has been rotated around its top-left corner (X, Y).
+func (o Object) RotatedBounds() image.Rectangle {
+	if o.Rotation == 0 {
+		return o.Bounds()
+	}
+	w, h := o.Width, o.Height
+	return rotatedRect(o.X, o.Y, o.Rotation, [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}})
+}
+
+// rotatedRect returns the axis-aligned bounding rectangle in pixels of the
+// given corners, which are relative to (x, y), after they have been rotated
+// clockwise by the given number of degrees around (x, y).
+func rotatedRect(x, y, rotation float64, corners [4][2]float64) image.Rectangle {
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range corners {
+		// Rotate clockwise, as the y-axis points down.
+		cx := x + c[0]*cos - c[1]*sin
+		cy := y + c[0]*sin + c[1]*cos
+		minX, maxX = math.Min(minX, cx), math.Max(maxX, cx)
+		minY, maxY = math.Min(minY, cy), math.Max(maxY, cy)
+	}
+	return image.Rect(floor(minX), floor(minY), ceil(maxX), ceil(maxY))
+}
+
+// epsilon is the tolerance used to absorb floating-point rounding errors when
+// converting coordinates to integers.
+const epsilon = 1e-9
+
+// floor returns the greatest integer less than or equal to x, treating values
+// within epsilon of an integer as that integer.
+func floor(x float64) int {
+	return int(math.Floor(x + epsilon))
+}
+
+// ceil returns the least integer greater than or equal to x, treating values
+// within epsilon of an integer as that integer.
+func ceil(x float64) int {
+	return int(math.Ceil(x - epsilon))
+}
+
+// AppendObject appends the object to the object layer with the given index, and
+// returns a pointer to the appended object. The object is assigned the next
+// available object ID of the map, and NextObjectID is incremented.
+func (m *Map) AppendObject(layerIndex int, o Object) (*Object, error) {
+	if layerIndex < 0 || layerIndex >= len(m.ObjectLayers) {
+		return nil, fmt.Errorf("AppendObject: invalid object layer index %d.", layerIndex)
+	}
+	if m.NextObjectID == 0 {
+		// Maps created by older versions of Tiled lack the next object ID.
+		m.NextObjectID = 1
+		for _, ol := range m.ObjectLayers {
+			for _, obj := range ol.Objects {
+				m.NextObjectID = max(m.NextObjectID, obj.ID+1)
+			}
+		}
+	}
+	o.ID = m.NextObjectID
+	m.NextObjectID++
+	ol := &m.ObjectLayers[layerIndex]
+	ol.Objects = append(ol.Objects, o)
+	return &ol.Objects[len(ol.Objects)-1], nil
+}
+
+// DrawOrderedObjects returns the objects of the object layer in the order they
+// are drawn, as specified by DrawOrder. Objects are sorted by their y
+// coordinate in "topdown" draw order and kept in document order in "index"
+// draw order.
+func (ol *ObjectLayer) DrawOrderedObjects() []*Object {
+	objects := make([]*Object, len(ol.Objects))
+	for i := range ol.Objects {
+		objects[i] = &ol.Objects[i]
+	}
+	if ol.DrawOrder != "index" {
+		sort.Stable(byY(objects))
+	}
+	return objects
+}
+
+// byY implements sort.Interface, sorting objects by y coordinate.
+type byY []*Object
+
+func (s byY) Len() int           { return len(s) }
+func (s byY) Less(i, j int) bool { return s[i].Y < s[j].Y }
+func (s byY) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ObjectBounds returns the union of the bounding rectangles in pixels of all
+// objects of the map, taking the rotation of objects and the offset of object
+// layers into account. Tile objects are aligned to the bottom-left and have the
+// size of the tiles of their tileset. The boolean result is false if the map has
+// no objects.
+func (m *Map) ObjectBounds() (image.Rectangle, bool) {
+	var bounds image.Rectangle
+	found := false
+	for i := range m.ObjectLayers {
+		ol := &m.ObjectLayers[i]
+		offset := image.Pt(ol.OffsetX, ol.OffsetY)
+		for j := range ol.Objects {
+			r := m.objectRect(&ol.Objects[j]).Add(offset)
+			if !found {
+				bounds = r
+				found = true
+				continue
+			}
+			// Union is not used, as it ignores empty rectangles (e.g. point
+			// objects).
+			bounds.Min.X = min(bounds.Min.X, r.Min.X)
+			bounds.Min.Y = min(bounds.Min.Y, r.Min.Y)
+			bounds.Max.X = max(bounds.Max.X, r.Max.X)
+			bounds.Max.Y = max(bounds.Max.Y, r.Max.Y)
+		}
+	}
+	return bounds, found
+}
+
+// objectRect returns the bounding rectangle in pixels of the given object,
+// taking the rotation of the object and the alignment and size of tile objects
+// into account.
+func (m *Map) objectRect(o *Object) image.Rectangle {
+	if o.GID == 0 {
+		return o.RotatedBounds()
+	}
+	// Tile objects are rotated around their bottom-left corner.
+	w, h := m.ObjectPixelSize(o)
+	return rotatedRect(o.X, o.Y, o.Rotation, [4][2]float64{{0, -h}, {w, -h}, {w, 0}, {0, 0}})
+}
+
+// ObjectPixelSize returns the size in pixels of the given object. Tile objects
+// have the tile size of their tileset, while other objects have the size given
+// by Width and Height. Tile objects whose tileset cannot be located fall back to
+// Width and Height.
+func (m *Map) ObjectPixelSize(o *Object) (w, h float64) {
+	if o.GID != 0 {
+		if ts := m.TilesetForGID(o.GID.GlobalTileID()); ts != nil {
+			return float64(ts.TileWidth), float64(ts.TileHeight)
+		}
+	}
+	return o.Width, o.Height
+}
+
+// Segments returns the line segments between consecutive points of the
+// polyline, as pairs of start and end points. A polyline of n points has n-1
+// segments. The points are relative to the location of the parent object.
+func (p Polyline) Segments() ([][2]image.Point, error) {
+	points, err := parsePoints(p.Points)
+	if err != nil {
+		return nil, err
+	}
+	var segments [][2]image.Point
+	for i := 1; i < len(points); i++ {
+		segments = append(segments, [2]image.Point{points[i-1], points[i]})
+	}
+	return segments, nil
+}
+
+// parsePoints parses a space-delimited list of x,y coordinates. Fractional
+// coordinates are rounded to the nearest integer.
+func parsePoints(s string) ([]image.Point, error) {
+	var points []image.Point
+	for _, field := range strings.Fields(s) {
+		pos := strings.Index(field, ",")
+		if pos == -1 {
+			return nil, fmt.Errorf("parsePoints: invalid point '%s'.", field)
+		}
+		x, err := strconv.ParseFloat(field[:pos], 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(field[pos+1:], 64)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, image.Pt(int(math.Floor(x+0.5)), int(math.Floor(y+0.5))))
+	}
+	return points, nil
+}
+
+// min returns the smaller of x or y.
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// max returns the larger of x or y.
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,573 @@
+package tmx
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestMapObjectBounds(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="10" height="10" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="24">
+  <image source="tiles.png" width="64" height="96"/>
+ </tileset>
+ <objectgroup name="a">
+  <object id="1" x="10" y="20" width="30" height="40"/>
+ </objectgroup>
+ <objectgroup name="b" offsetx="5" offsety="-5">
+  <object id="2" gid="1" x="100" y="100"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := m.ObjectBounds()
+	if !ok {
+		t.Fatal("expected objects")
+	}
+	// The tile object is aligned to the bottom-left and offset by its layer.
+	want := image.Rect(10, 20, 121, 95)
+	if got != want {
+		t.Errorf("object bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestMapObjectBoundsRotated(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="10" height="10" tilewidth="32" tileheight="32">
+ <objectgroup name="a">
+  <object id="1" x="100" y="100" width="40" height="10" rotation="90"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := m.ObjectBounds()
+	if !ok {
+		t.Fatal("expected objects")
+	}
+	// Rotated clockwise by 90 degrees around the top-left corner.
+	want := image.Rect(90, 100, 100, 140)
+	if got != want {
+		t.Errorf("object bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestMapObjectBoundsEmpty(t *testing.T) {
+	m := NewMap("orthogonal", 2, 2, 32, 32)
+	if _, ok := m.ObjectBounds(); ok {
+		t.Error("expected no objects")
+	}
+}
+
+func TestPolylineSegments(t *testing.T) {
+	golden := []struct {
+		points string
+		want   [][2]image.Point
+	}{
+		{points: "", want: nil},
+		{points: "0,0", want: nil},
+		{
+			points: "0,0 10,5",
+			want:   [][2]image.Point{{{0, 0}, {10, 5}}},
+		},
+		{
+			// Fractional coordinates are rounded to the nearest integer.
+			points: "0,0 10.6,-5.2 -3.5,2.4",
+			want:   [][2]image.Point{{{0, 0}, {11, -5}}, {{11, -5}, {-3, 2}}},
+		},
+	}
+	for _, g := range golden {
+		got, err := Polyline{Points: g.points}.Segments()
+		if err != nil {
+			t.Errorf("%q: unexpected error; %v", g.points, err)
+			continue
+		}
+		if len(got) != len(g.want) {
+			t.Errorf("%q: number of segments mismatch; expected %d, got %d", g.points, len(g.want), len(got))
+			continue
+		}
+		for i := range g.want {
+			if got[i] != g.want[i] {
+				t.Errorf("%q: segment %d mismatch; expected %v, got %v", g.points, i, g.want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestPolylineSegmentsInvalid(t *testing.T) {
+	for _, points := range []string{"0,0 10", "0,0 x,5", "0,0 5,y"} {
+		if _, err := (Polyline{Points: points}).Segments(); err == nil {
+			t.Errorf("%q: expected error for invalid point", points)
+		}
+	}
+}
+
+func TestObjectRotatedBounds(t *testing.T) {
+	golden := []struct {
+		o    Object
+		want image.Rectangle
+	}{
+		// Fractional coordinates are expanded to whole pixels.
+		{o: Object{X: 0.5, Y: 1.5, Width: 10, Height: 2}, want: image.Rect(0, 1, 11, 4)},
+		// Rounding errors of sin and cos are absorbed.
+		{o: Object{Width: 10, Height: 5, Rotation: 90}, want: image.Rect(-5, 0, 0, 10)},
+		{o: Object{Width: 10, Height: 5, Rotation: -90}, want: image.Rect(0, -10, 5, 0)},
+		{o: Object{X: 10, Y: 10, Width: 4, Height: 2, Rotation: 180}, want: image.Rect(6, 8, 10, 10)},
+		{o: Object{Width: 10, Height: 10, Rotation: 45}, want: image.Rect(-8, 0, 8, 15)},
+	}
+	for _, g := range golden {
+		got := g.o.RotatedBounds()
+		if got != g.want {
+			t.Errorf("rotation=%v: bounds mismatch; expected %v, got %v", g.o.Rotation, g.want, got)
+		}
+	}
+	// Bounds ignores the rotation.
+	o := Object{X: 1, Y: 2, Width: 3, Height: 4, Rotation: 90}
+	if got, want := o.Bounds(), image.Rect(1, 2, 4, 6); got != want {
+		t.Errorf("bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestObjectVisible(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="a">
+  <object id="1"/>
+  <object id="2" visible="0"/>
+  <object id="3" visible="1"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Objects are visible by default.
+	want := []bool{true, false, true}
+	for i, o := range m.ObjectLayers[0].Objects {
+		if o.Visible != want[i] {
+			t.Errorf("object %d: visibility mismatch; expected %v, got %v", o.ID, want[i], o.Visible)
+		}
+	}
+}
+
+func TestObjectLayerOffset(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="a" offsetx="-4" offsety="12"/>
+ <objectgroup name="b"/>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ol := m.ObjectLayers[0]; ol.OffsetX != -4 || ol.OffsetY != 12 {
+		t.Errorf("offset mismatch; expected (-4, 12), got (%d, %d)", ol.OffsetX, ol.OffsetY)
+	}
+	if ol := m.ObjectLayers[1]; ol.OffsetX != 0 || ol.OffsetY != 0 {
+		t.Errorf("offset mismatch; expected (0, 0), got (%d, %d)", ol.OffsetX, ol.OffsetY)
+	}
+}
+
+func TestObjectLayerDrawOrderedObjects(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="topdown">
+  <object id="1" y="30"/>
+  <object id="2" y="10"/>
+  <object id="3" y="30"/>
+  <object id="4" y="20"/>
+ </objectgroup>
+ <objectgroup name="index" draworder="index">
+  <object id="1" y="30"/>
+  <object id="2" y="10"/>
+  <object id="3" y="30"/>
+  <object id="4" y="20"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		layer int
+		want  []int
+	}{
+		// Sorted by y coordinate, keeping the document order of equal
+		// coordinates.
+		{layer: 0, want: []int{2, 4, 1, 3}},
+		{layer: 1, want: []int{1, 2, 3, 4}},
+	}
+	for _, g := range golden {
+		ol := &m.ObjectLayers[g.layer]
+		var got []int
+		for _, o := range ol.DrawOrderedObjects() {
+			got = append(got, o.ID)
+		}
+		if len(got) != len(g.want) {
+			t.Errorf("%s: objects mismatch; expected %v, got %v", ol.Name, g.want, got)
+			continue
+		}
+		for i := range g.want {
+			if got[i] != g.want[i] {
+				t.Errorf("%s: objects mismatch; expected %v, got %v", ol.Name, g.want, got)
+				break
+			}
+		}
+		// The objects of the layer are left in document order.
+		if ol.Objects[0].ID != 1 {
+			t.Errorf("%s: object order of layer modified", ol.Name)
+		}
+	}
+}
+
+func TestMapAppendObject(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32" nextobjectid="7">
+ <objectgroup name="a">
+  <object id="3"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := m.AppendObject(0, Object{ID: 100, Name: "door"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.ID != 7 || o.Name != "door" {
+		t.Errorf("object mismatch; expected ID 7 and name %q, got ID %d and name %q", "door", o.ID, o.Name)
+	}
+	if got, want := m.NextObjectID, 8; got != want {
+		t.Errorf("next object ID mismatch; expected %d, got %d", want, got)
+	}
+	if m.ObjectByID(7) != o {
+		t.Error("appended object not found")
+	}
+	if _, err := m.AppendObject(1, Object{}); err == nil {
+		t.Error("expected error for invalid object layer index")
+	}
+}
+
+func TestMapAppendObjectMissingNextObjectID(t *testing.T) {
+	// Maps created by older versions of Tiled lack the next object ID.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="a">
+  <object id="3"/>
+ </objectgroup>
+ <objectgroup name="b">
+  <object id="9"/>
+  <object id="4"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := m.AppendObject(0, Object{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := o.ID, 10; got != want {
+		t.Errorf("object ID mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestObjectText(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="a">
+  <object id="1" width="64" height="16">
+   <text>Hello</text>
+  </object>
+  <object id="2" width="64" height="16">
+   <text fontfamily="Serif" pixelsize="12" wrap="1" color="#80ff0000" bold="1" italic="1" halign="center" valign="bottom">Hello
+world</text>
+  </object>
+  <object id="3"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := m.ObjectLayers[0].Objects
+	// Default values.
+	want := Text{FontFamily: "sans-serif", PixelSize: 16, Color: "#000000", HAlign: "left", VAlign: "top", Text: "Hello"}
+	if got := objects[0].Text; got == nil || *got != want {
+		t.Errorf("text mismatch; expected %+v, got %+v", want, got)
+	}
+	want = Text{FontFamily: "Serif", PixelSize: 12, Wrap: true, Color: "#80ff0000", Bold: true, Italic: true, HAlign: "center", VAlign: "bottom", Text: "Hello\nworld"}
+	if got := objects[1].Text; got == nil || *got != want {
+		t.Errorf("text mismatch; expected %+v, got %+v", want, got)
+	}
+	if objects[2].Text != nil {
+		t.Errorf("text mismatch; expected nil, got %+v", objects[2].Text)
+	}
+	c, err := ParseColor(objects[1].Text.Color)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Color{R: 0xFF, A: 0x80}); c != want {
+		t.Errorf("text color mismatch; expected %v, got %v", want, c)
+	}
+}
+
+func TestMapObjectPixelSize(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="24" tilecount="4"/>
+ <objectgroup name="a">
+  <object id="1" gid="2" x="0" y="0" width="64" height="64"/>
+  <object id="2" gid="1073741826" x="0" y="0"/>
+  <object id="3" x="0" y="0" width="10.5" height="20.25"/>
+  <object id="4" gid="9" x="0" y="0" width="8" height="4"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		w, h float64
+	}{
+		// Tile objects have the tile size of their tileset.
+		{w: 16, h: 24},
+		{w: 16, h: 24},
+		{w: 10.5, h: 20.25},
+		// Tile objects without tileset fall back to the object size.
+		{w: 8, h: 4},
+	}
+	for i, g := range golden {
+		o := &m.ObjectLayers[0].Objects[i]
+		w, h := m.ObjectPixelSize(o)
+		if w != g.w || h != g.h {
+			t.Errorf("object %d: size mismatch; expected %vx%v, got %vx%v", o.ID, g.w, g.h, w, h)
+		}
+	}
+}
+
+func TestObjectBoundsFractional(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="24" tilecount="4"/>
+ <objectgroup name="a">
+  <object id="1" x="10.5" y="-3.25" width="20.25" height="7.5"/>
+  <object id="2" x="1" y="2" width="3" height="4"/>
+  <object id="3" gid="1" x="0.5" y="30.5"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := m.ObjectLayers[0].Objects
+	o := objs[0]
+	if o.X != 10.5 || o.Y != -3.25 || o.Width != 20.25 || o.Height != 7.5 {
+		t.Errorf("object mismatch; expected (10.5, -3.25) of size 20.25x7.5, got (%v, %v) of size %vx%v", o.X, o.Y, o.Width, o.Height)
+	}
+	golden := []struct {
+		want image.Rectangle
+	}{
+		// Fractional coordinates are expanded to whole pixels.
+		{want: image.Rect(10, -4, 31, 5)},
+		{want: image.Rect(1, 2, 4, 6)},
+	}
+	for i, g := range golden {
+		if got := objs[i].Bounds(); got != g.want {
+			t.Errorf("object %d: bounds mismatch; expected %v, got %v", objs[i].ID, g.want, got)
+		}
+	}
+	// The tile object is aligned to the bottom-left.
+	got, ok := m.ObjectBounds()
+	if !ok {
+		t.Fatal("expected objects")
+	}
+	if want := image.Rect(0, -4, 31, 31); got != want {
+		t.Errorf("object bounds mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestObjectPropertiesWithShape(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="a">
+  <object id="1">
+   <properties>
+    <property name="kind" value="before"/>
+   </properties>
+   <polygon points="0,0 10,0 10,10"/>
+  </object>
+  <object id="2">
+   <polygon points="0,0 20,0 20,20"/>
+   <properties>
+    <property name="kind" value="after"/>
+   </properties>
+  </object>
+  <object id="3">
+   <polyline points="0,0 5,5"/>
+   <properties>
+    <property name="kind" value="polyline"/>
+   </properties>
+  </object>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		kind   string
+		points string
+	}{
+		{kind: "before", points: "0,0 10,0 10,10"},
+		{kind: "after", points: "0,0 20,0 20,20"},
+		{kind: "polyline", points: "0,0 5,5"},
+	}
+	for i, g := range golden {
+		o := &m.ObjectLayers[0].Objects[i]
+		checkProps(t, o.Properties, map[string]string{"kind": g.kind})
+		points := o.Polygon.Points
+		if o.Polyline.Points != "" {
+			points = o.Polyline.Points
+		}
+		if points != g.points {
+			t.Errorf("object %d: points mismatch; expected %q, got %q", o.ID, g.points, points)
+		}
+	}
+}
+
+func TestMapEachObject(t *testing.T) {
+	const src = `
+<map version="1.2" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="a">
+  <object id="1" name="door"/>
+  <object id="2" name="key"/>
+ </objectgroup>
+ <group name="g">
+  <objectgroup name="b">
+   <object id="3" name="chest"/>
+  </objectgroup>
+ </group>
+ <objectgroup name="c">
+  <object id="4" name="player"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Object layers of group layers are visited in document order.
+	want := []string{"a/door", "a/key", "b/chest", "c/player"}
+	var got []string
+	m.EachObject(func(layerName string, o *Object) {
+		got = append(got, layerName+"/"+o.Name)
+		o.Name = strings.ToUpper(o.Name)
+	})
+	if !equalStrings(got, want) {
+		t.Errorf("objects mismatch; expected %v, got %v", want, got)
+	}
+	// The objects are modified in place.
+	if o := m.ObjectByID(3); o == nil || o.Name != "CHEST" {
+		t.Errorf("expected modified object, got %+v", o)
+	}
+}
+
+func TestObjectClass(t *testing.T) {
+	const src = `
+<map version="1.9" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="objects">
+  <object id="1" type="enemy"/>
+  <object id="2" class="enemy"/>
+  <object id="3" type="enemy" class="boss"/>
+  <object id="4" class="npc" visible="0"/>
+  <object id="5"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		id      int
+		typ     string
+		visible bool
+	}{
+		{id: 1, typ: "enemy", visible: true},
+		{id: 2, typ: "enemy", visible: true},
+		// The type attribute takes precedence over the class attribute.
+		{id: 3, typ: "enemy", visible: true},
+		{id: 4, typ: "npc", visible: false},
+		{id: 5, typ: "", visible: true},
+	}
+	for _, g := range golden {
+		o := m.ObjectByID(g.id)
+		if o == nil {
+			t.Errorf("object %d missing", g.id)
+			continue
+		}
+		if o.Type != g.typ {
+			t.Errorf("object %d: type mismatch; expected %q, got %q", g.id, g.typ, o.Type)
+		}
+		if o.Visible != g.visible {
+			t.Errorf("object %d: visibility mismatch; expected %v, got %v", g.id, g.visible, o.Visible)
+		}
+	}
+}
+
+func TestMapObjectGIDs(t *testing.T) {
+	const src = `
+<map version="1.2" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="8"/>
+ <objectgroup name="a">
+  <object id="1" gid="5"/>
+  <object id="2" gid="2"/>
+  <object id="3" x="10" y="10" width="4" height="4"/>
+ </objectgroup>
+ <group name="g">
+  <objectgroup name="b">
+   <object id="4" gid="2147483653"/>
+   <object id="5" gid="7"/>
+  </objectgroup>
+ </group>
+ <objectgroup name="c">
+  <object id="6" gid="2"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GIDs shared by several objects are listed once, and flip flags are
+	// cleared.
+	want := []int{2, 5, 7}
+	if got := m.ObjectGIDs(); !equalInts(got, want) {
+		t.Errorf("object GIDs mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestMapObjectGIDsEmpty(t *testing.T) {
+	m := NewMap("orthogonal", 1, 1, 32, 32)
+	if got := m.ObjectGIDs(); len(got) != 0 {
+		t.Errorf("expected no object GIDs, got %v", got)
+	}
+}
+
+// equalInts reports whether a and b contain the same integers in the same
+// order.
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
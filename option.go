@@ -0,0 +1,134 @@
+package tmx
+
+import "sync"
+
+// An Option configures how tmx files are parsed.
+type Option func(*config)
+
+// config holds the configuration used while parsing tmx files.
+type config struct {
+	// strictCompression disables the detection of compressed layer data which
+	// lacks a compression attribute.
+	strictCompression bool
+	// externalDataDir is the directory relative to which external layer data
+	// files are loaded, or "" if external layer data is disabled.
+	externalDataDir string
+	// lenient enables recovery from common errors in malformed tmx files.
+	lenient bool
+	// dir is the directory relative to which external tilesets are loaded, or
+	// "" if the location of the tmx file is unknown.
+	dir string
+	// readTSX reads the provided TSX file and returns the parsed tileset.
+	readTSX func(tsxPath string) (*Tileset, error)
+	// eagerChunks decodes the chunks of infinite maps while parsing, rather
+	// than when they are first accessed.
+	eagerChunks bool
+	// layers contains the names of the layers which are decoded while parsing,
+	// or is nil if every layer is decoded while parsing.
+	layers []string
+	// warnings points to the list of errors recovered from in lenient mode.
+	warnings *ErrorList
+	// nwarnings is the total number of errors recovered from in lenient mode,
+	// including those exceeding the error budget.
+	nwarnings int
+	// mu protects warnings and nwarnings, as layer data which is decoded on
+	// demand may be decoded concurrently.
+	mu sync.Mutex
+}
+
+// newConfig returns a parsing configuration with the provided options applied.
+func newConfig(opts []Option) *config {
+	conf := &config{readTSX: readTSX}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	return conf
+}
+
+// decodeLayer returns true if the named layer should be decoded while parsing.
+func (conf *config) decodeLayer(name string) bool {
+	if conf.layers == nil {
+		return true
+	}
+	for _, layer := range conf.layers {
+		if layer == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WithStrictCompression disables the detection of gzip and zlib compressed
+// layer data which lacks a compression attribute. By default such data is
+// decompressed transparently, as some third-party exporters omit the attribute.
+func WithStrictCompression() Option {
+	return func(conf *config) {
+		conf.strictCompression = true
+	}
+}
+
+// WithExternalData enables loading csv layer data from external files, which
+// are located relative to dir. The layer data is treated as a reference to an
+// external file if it consists of a single relative path with a ".csv"
+// extension.
+//
+// Note: External layer data is not part of the TMX file format. It is
+// supported for compatibility with third-party pipelines.
+func WithExternalData(dir string) Option {
+	return func(conf *config) {
+		conf.externalDataDir = dir
+	}
+}
+
+// WithLenient enables lenient parsing, which recovers from common errors in
+// malformed tmx files instead of failing. Lenient parsing may mask corrupt data
+// and is therefore disabled by default.
+//
+// Recovered errors are recorded in the Warnings of the map, up to an error
+// budget of 100 errors. The remaining errors are summarized by a final entry.
+//
+// Recovered errors:
+//   - layer data compressed using a different method than declared.
+//   - GIDs out of range in csv layer data, which are replaced by the empty GID
+//     0.
+//   - invalid base64 layer data which also contains a <tile> element for each
+//     cell, in which case the <tile> elements are used.
+func WithLenient() Option {
+	return func(conf *config) {
+		conf.lenient = true
+	}
+}
+
+// WithEagerChunks decodes every chunk of the tile layers of infinite maps while
+// parsing, which reports errors in the chunk data as parse errors.
+//
+// By default, each chunk is decoded the first time it is accessed, which saves
+// time and memory for huge maps of which only parts are used. Use Layer.Decode
+// to decode every chunk of a layer and check for errors; other accessors panic
+// if decoding fails. The chunks may be accessed concurrently, and each chunk is
+// decoded once. In lenient mode, errors recovered from while decoding on demand
+// are added to the Warnings of the map, which must not be read while such
+// chunks are accessed.
+func WithEagerChunks() Option {
+	return func(conf *config) {
+		conf.eagerChunks = true
+	}
+}
+
+// WithLayers restricts the decoding of layer data while parsing to the named
+// layers. The data of other layers is decoded on demand, the first time it is
+// accessed (see Layer.Decode). Errors in the data of other layers are therefore
+// not reported while parsing.
+//
+// The layers may be accessed concurrently, and each layer is decoded once. In
+// lenient mode, errors recovered from while decoding on demand are added to the
+// Warnings of the map, which must not be read while such layers are accessed.
+func WithLayers(names ...string) Option {
+	return func(conf *config) {
+		conf.layers = append(conf.layers, names...)
+		if conf.layers == nil {
+			// Decode no layer while parsing.
+			conf.layers = []string{}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tmx
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// twoLayerMap is a map with two tile layers, "collision" and "ground".
+const twoLayerMap = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="32" tileheight="32">
+ <layer name="collision" width="2" height="1">
+  <data encoding="csv">1,2</data>
+ </layer>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">3,4</data>
+ </layer>
+</map>`
+
+func TestWithLayers(t *testing.T) {
+	m, err := NewFile(strings.NewReader(twoLayerMap), WithLayers("collision"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collision, ground := &m.Layers[0], &m.Layers[1]
+	if collision.Data.gids == nil {
+		t.Error("selected layer not decoded while parsing")
+	}
+	if got, want := collision.GetGID(1, 0), 2; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+	// Accessing the selected layer never decodes other layers.
+	if ground.Data.gids != nil {
+		t.Error("skipped layer decoded while parsing")
+	}
+	// Skipped layers are decoded on demand.
+	if got, want := ground.GetGID(1, 0), 4; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestWithLayersConcurrent(t *testing.T) {
+	m, err := NewFile(strings.NewReader(twoLayerMap), WithLayers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range m.Layers {
+				m.Layers[i].GetGID(0, 0)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := m.Layers[1].GetGID(0, 0), 3; got != want {
+		t.Errorf("GID mismatch; expected %d, got %d", want, got)
+	}
+}
+
+func TestWithLayersError(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="32" tileheight="32">
+ <layer name="broken" width="2" height="1">
+  <data encoding="csv">1,2,3</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src), WithLayers())
+	if err != nil {
+		t.Fatalf("unexpected error while parsing; %v", err)
+	}
+	if err := m.Layers[0].Decode(); err == nil {
+		t.Error("expected error while decoding")
+	}
+}
+
+func TestWithExternalData(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"ground.csv": "1,2,\n3,4\n"})
+	src := layerMapSource(`encoding="csv"`, "\n   ground.csv\n  ")
+	m, err := NewFile(strings.NewReader(src), WithExternalData(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGIDs(t, m, []int{1, 2, 3, 4})
+	// Without the option, the reference is parsed as csv data.
+	if _, err := NewFile(strings.NewReader(src)); err == nil {
+		t.Error("expected error for external data without WithExternalData")
+	}
+	// Missing external files are reported.
+	src = layerMapSource(`encoding="csv"`, "missing.csv")
+	if _, err := NewFile(strings.NewReader(src), WithExternalData(dir)); err == nil {
+		t.Error("expected error for missing external file")
+	}
+}
+
+func TestWithLenientCompression(t *testing.T) {
+	golden := []struct {
+		declared, actual string
+	}{
+		{declared: "gzip", actual: "zlib"},
+		{declared: "zlib", actual: "gzip"},
+	}
+	for _, g := range golden {
+		data := encodeGIDs([]uint32{1, 2, 3, 4}, "base64", g.actual)
+		src := layerMapSource(`encoding="base64" compression="`+g.declared+`"`, data)
+		if _, err := NewFile(strings.NewReader(src)); err == nil {
+			t.Errorf("%s as %s: expected error for mislabeled compression", g.actual, g.declared)
+		}
+		m, err := NewFile(strings.NewReader(src), WithLenient())
+		if err != nil {
+			t.Errorf("%s as %s: unexpected error; %v", g.actual, g.declared, err)
+			continue
+		}
+		checkGIDs(t, m, []int{1, 2, 3, 4})
+		if got, want := len(m.Warnings), 1; got != want {
+			t.Errorf("%s as %s: number of warnings mismatch; expected %d, got %d", g.actual, g.declared, want, got)
+		}
+	}
+}
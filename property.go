@@ -0,0 +1,138 @@
+package tmx
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// Get returns the value of the named property. The boolean result is false if
+// no such property exists.
+func (ps Properties) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// merge returns the properties of ps overridden by the properties of override
+// with the same name. Properties of override which are not present in ps are
+// appended.
+func (ps Properties) merge(override Properties) Properties {
+	merged := make(Properties, len(ps), len(ps)+len(override))
+	copy(merged, ps)
+	for _, p := range override {
+		found := false
+		for i := range merged {
+			if merged[i].Name == p.Name {
+				merged[i] = p
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// EffectiveTileProps returns the properties which apply to the tile of the
+// given global tile ID. Properties of the tile override properties of its
+// tileset, which in turn override properties of the map. The flip flags must be
+// cleared from gid.
+func (m *Map) EffectiveTileProps(gid int) Properties {
+	props := m.Properties.merge(nil)
+	ts := m.TilesetForGID(gid)
+	if ts == nil {
+		return props
+	}
+	props = props.merge(ts.Properties)
+	if t := ts.tileInfo(gid - ts.FirstGID); t != nil {
+		props = props.merge(t.Properties)
+	}
+	return props
+}
+
+// ObjectEffectiveProps returns the properties which apply to the given object.
+// Tile objects inherit the properties defined on their tile in the tileset, and
+// properties of the object override properties of the tile.
+func (m *Map) ObjectEffectiveProps(o *Object) Properties {
+	var props Properties
+	if o.GID != 0 {
+		gid := o.GID.GlobalTileID()
+		if ts := m.TilesetForGID(gid); ts != nil {
+			if t := ts.tileInfo(gid - ts.FirstGID); t != nil {
+				props = t.Properties
+			}
+		}
+	}
+	return props.merge(o.Properties)
+}
+
+// WithClassDefaults merges the default properties of object classes into the
+// properties of the objects of the map. The defaults map is keyed by class
+// name, which is matched against the Type of each object, and properties of
+// the object override the defaults of its class. Objects of classes without
+// defaults are left untouched.
+func (m *Map) WithClassDefaults(defaults map[string]Properties) {
+	m.EachObject(func(_ string, o *Object) {
+		if props, ok := defaults[o.Type]; ok {
+			o.Properties = props.merge(o.Properties)
+		}
+	})
+}
+
+// AsObjectID returns the ID of the object referenced by an object property. The
+// boolean result is false if the property is not a valid object reference.
+func (p Property) AsObjectID() (int, bool) {
+	if p.Type != "object" {
+		return 0, false
+	}
+	id, err := strconv.Atoi(p.Value)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// AsColor returns the color of a color property. The boolean result is false if
+// the property is not a valid color. An empty value denotes that no color is
+// set, and is reported as invalid.
+func (p Property) AsColor() (Color, bool) {
+	if p.Type != "color" {
+		return Color{}, false
+	}
+	c, err := ParseColor(p.Value)
+	if err != nil {
+		return Color{}, false
+	}
+	return c, true
+}
+
+// AsFile returns the path of the file referenced by a file property, resolved
+// against baseDir (typically the directory of the tmx file). Absolute paths are
+// returned unchanged. The empty string is returned if the property is not a
+// file property or references no file.
+func (p Property) AsFile(baseDir string) string {
+	if p.Type != "file" || p.Value == "" {
+		return ""
+	}
+	path := filepath.FromSlash(p.Value)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// ResolveObject returns the object referenced by the provided object property,
+// or nil if the property doesn't reference an object of the map.
+func (m *Map) ResolveObject(p Property) *Object {
+	id, ok := p.AsObjectID()
+	if !ok || id == 0 {
+		// An ID of 0 denotes that no object is referenced.
+		return nil
+	}
+	return m.ObjectByID(id)
+}
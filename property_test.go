@@ -0,0 +1,356 @@
+package tmx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkProps reports an error if the named properties are missing from props
+// or have different values than want.
+func checkProps(t *testing.T, props Properties, want map[string]string) {
+	if len(props) != len(want) {
+		t.Errorf("number of properties mismatch; expected %d, got %d", len(want), len(props))
+	}
+	for name, value := range want {
+		got, ok := props.Get(name)
+		if !ok {
+			t.Errorf("property %q missing", name)
+			continue
+		}
+		if got != value {
+			t.Errorf("property %q mismatch; expected %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestMapEffectiveTileProps(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <properties>
+  <property name="a" value="map"/>
+  <property name="b" value="map"/>
+  <property name="c" value="map"/>
+ </properties>
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="4">
+  <properties>
+   <property name="b" value="tileset"/>
+   <property name="c" value="tileset"/>
+  </properties>
+  <tile id="1">
+   <properties>
+    <property name="c" value="tile"/>
+    <property name="d" value="tile"/>
+   </properties>
+  </tile>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkProps(t, m.EffectiveTileProps(2), map[string]string{"a": "map", "b": "tileset", "c": "tile", "d": "tile"})
+	// Tiles without properties inherit the properties of their tileset.
+	checkProps(t, m.EffectiveTileProps(1), map[string]string{"a": "map", "b": "tileset", "c": "tileset"})
+	// GIDs outside of the tilesets only have the properties of the map.
+	checkProps(t, m.EffectiveTileProps(0), map[string]string{"a": "map", "b": "map", "c": "map"})
+	// The properties of the map are left unchanged.
+	checkProps(t, m.Properties, map[string]string{"a": "map", "b": "map", "c": "map"})
+}
+
+func TestMapResolveObject(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <properties>
+  <property name="spawn" type="object" value="2"/>
+  <property name="none" type="object" value="0"/>
+  <property name="missing" type="object" value="3"/>
+  <property name="plain" value="2"/>
+ </properties>
+ <objectgroup name="a">
+  <object id="1" name="door"/>
+ </objectgroup>
+ <objectgroup name="b">
+  <object id="2" name="player"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		name string
+		want string
+	}{
+		{name: "spawn", want: "player"},
+		// An ID of 0 references no object.
+		{name: "none", want: ""},
+		{name: "missing", want: ""},
+		// Only object properties reference objects.
+		{name: "plain", want: ""},
+	}
+	for i, g := range golden {
+		p := m.Properties[i]
+		if p.Name != g.name {
+			t.Fatalf("property name mismatch; expected %q, got %q", g.name, p.Name)
+		}
+		var got string
+		if o := m.ResolveObject(p); o != nil {
+			got = o.Name
+		}
+		if got != g.want {
+			t.Errorf("%s: object mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+	if id, ok := m.Properties[0].AsObjectID(); !ok || id != 2 {
+		t.Errorf("object ID mismatch; expected 2, got %d (ok=%v)", id, ok)
+	}
+}
+
+func TestMapObjectEffectiveProps(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="4">
+  <tile id="1">
+   <properties>
+    <property name="kind" value="chest"/>
+    <property name="gold" value="10"/>
+   </properties>
+  </tile>
+ </tileset>
+ <objectgroup name="objects">
+  <object id="1" gid="2"/>
+  <object id="2" gid="2">
+   <properties>
+    <property name="gold" value="50"/>
+    <property name="locked" type="bool" value="true"/>
+   </properties>
+  </object>
+  <object id="3" gid="2147483650"/>
+  <object id="4">
+   <properties>
+    <property name="kind" value="trigger"/>
+   </properties>
+  </object>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := m.ObjectLayers[0].Objects
+	checkProps(t, m.ObjectEffectiveProps(&objs[0]), map[string]string{"kind": "chest", "gold": "10"})
+	// Properties of the object override properties of its tile.
+	checkProps(t, m.ObjectEffectiveProps(&objs[1]), map[string]string{"kind": "chest", "gold": "50", "locked": "true"})
+	// Flip flags are ignored when looking up the tile.
+	checkProps(t, m.ObjectEffectiveProps(&objs[2]), map[string]string{"kind": "chest", "gold": "10"})
+	// Objects which are not tile objects only have their own properties.
+	checkProps(t, m.ObjectEffectiveProps(&objs[3]), map[string]string{"kind": "trigger"})
+	// The properties of the tile are left unchanged.
+	checkProps(t, m.EffectiveTileProps(2), map[string]string{"kind": "chest", "gold": "10"})
+}
+
+func TestPropertyAsColor(t *testing.T) {
+	golden := []struct {
+		p    Property
+		want Color
+		ok   bool
+	}{
+		{p: Property{Name: "tint", Type: "color", Value: "#ff00ff00"}, want: Color{G: 0xFF, A: 0xFF}, ok: true},
+		{p: Property{Name: "tint", Type: "color", Value: "#80ff0000"}, want: Color{R: 0xFF, A: 0x80}, ok: true},
+		// An empty value denotes that no color is set.
+		{p: Property{Name: "tint", Type: "color", Value: ""}},
+		{p: Property{Name: "tint", Type: "color", Value: "red"}},
+		// Only color properties hold colors.
+		{p: Property{Name: "tint", Value: "#ff00ff00"}},
+		{p: Property{Name: "tint", Type: "string", Value: "#ff00ff00"}},
+	}
+	for _, g := range golden {
+		got, ok := g.p.AsColor()
+		if ok != g.ok {
+			t.Errorf("%+v: validity mismatch; expected %v, got %v", g.p, g.ok, ok)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("%+v: color mismatch; expected %v, got %v", g.p, g.want, got)
+		}
+	}
+}
+
+func TestPropertiesSeveralElements(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <properties>
+  <property name="a" value="1"/>
+ </properties>
+ <properties>
+  <property name="b" value="2"/>
+  <property name="c" value="3"/>
+ </properties>
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="1">
+  <tile id="0">
+   <properties>
+    <property name="d" value="4"/>
+   </properties>
+   <properties>
+    <property name="e" value="5"/>
+   </properties>
+  </tile>
+ </tileset>
+ <layer name="ground" width="1" height="1">
+  <properties>
+   <property name="f" value="6"/>
+  </properties>
+  <data encoding="csv">1</data>
+  <properties>
+   <property name="g" value="7"/>
+  </properties>
+ </layer>
+ <objectgroup name="objects">
+  <object id="1">
+   <properties>
+    <property name="h" value="8"/>
+   </properties>
+   <properties>
+    <property name="i" value="9"/>
+   </properties>
+  </object>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		elem  string
+		props Properties
+		// Expected property names in document order.
+		want []string
+	}{
+		{elem: "map", props: m.Properties, want: []string{"a", "b", "c"}},
+		{elem: "tile", props: m.Tilesets[0].TilesInfo[0].Properties, want: []string{"d", "e"}},
+		{elem: "layer", props: m.Layers[0].Properties, want: []string{"f", "g"}},
+		{elem: "object", props: m.ObjectLayers[0].Objects[0].Properties, want: []string{"h", "i"}},
+	}
+	for _, g := range golden {
+		var got []string
+		for _, p := range g.props {
+			got = append(got, p.Name)
+		}
+		if !equalStrings(got, g.want) {
+			t.Errorf("%s: properties mismatch; expected %v, got %v", g.elem, g.want, got)
+		}
+	}
+}
+
+func TestPropertyAsFile(t *testing.T) {
+	base := filepath.Join("maps", "level1")
+	abs, err := filepath.Abs(filepath.Join("sounds", "door.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		p    Property
+		want string
+	}{
+		{p: Property{Type: "file", Value: "music.ogg"}, want: filepath.Join(base, "music.ogg")},
+		// File properties use forward slashes as path separators.
+		{p: Property{Type: "file", Value: "../sounds/door.wav"}, want: filepath.Join("maps", "sounds", "door.wav")},
+		// Absolute paths are returned unchanged.
+		{p: Property{Type: "file", Value: filepath.ToSlash(abs)}, want: abs},
+		{p: Property{Type: "file", Value: ""}, want: ""},
+		// Only file properties reference files.
+		{p: Property{Type: "string", Value: "music.ogg"}, want: ""},
+		{p: Property{Value: "music.ogg"}, want: ""},
+	}
+	for _, g := range golden {
+		if got := g.p.AsFile(base); got != g.want {
+			t.Errorf("%+v: path mismatch; expected %q, got %q", g.p, g.want, got)
+		}
+	}
+}
+
+func TestMapPropertiesPosition(t *testing.T) {
+	const layers = `
+ <layer name="ground" width="1" height="1">
+  <data encoding="csv">1</data>
+ </layer>
+ <objectgroup name="objects"/>`
+	const tilesets = `
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32" tilecount="1"/>`
+	const props = `
+ <properties>
+  <property name="music" value="theme.ogg"/>
+ </properties>`
+	golden := []struct {
+		desc     string
+		children string
+	}{
+		{desc: "before tilesets", children: props + tilesets + layers},
+		{desc: "after tilesets", children: tilesets + props + layers},
+		{desc: "after layers", children: tilesets + layers + props},
+		{desc: "after layers and before tilesets", children: layers + props + tilesets},
+	}
+	for _, g := range golden {
+		src := `<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">` + g.children + `</map>`
+		m, err := NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%s: unexpected error; %v", g.desc, err)
+			continue
+		}
+		checkProps(t, m.Properties, map[string]string{"music": "theme.ogg"})
+		if len(m.Tilesets) != 1 || len(m.Layers) != 1 || len(m.ObjectLayers) != 1 {
+			t.Errorf("%s: expected 1 tileset, 1 tile layer and 1 object layer, got %d, %d and %d", g.desc, len(m.Tilesets), len(m.Layers), len(m.ObjectLayers))
+		}
+		if gid := m.Layers[0].GetGID(0, 0); gid != 1 {
+			t.Errorf("%s: GID mismatch; expected 1, got %d", g.desc, gid)
+		}
+	}
+}
+
+func TestMapWithClassDefaults(t *testing.T) {
+	const src = `
+<map version="1.9" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <objectgroup name="objects">
+  <object id="1" class="enemy"/>
+  <object id="2" type="enemy">
+   <properties>
+    <property name="speed" type="int" value="5"/>
+   </properties>
+  </object>
+  <object id="3" class="door"/>
+ </objectgroup>
+ <group name="g">
+  <objectgroup name="nested">
+   <object id="4" class="enemy"/>
+  </objectgroup>
+ </group>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := map[string]Properties{
+		"enemy": {
+			{Name: "speed", Type: "int", Value: "2"},
+			{Name: "hostile", Type: "bool", Value: "true"},
+		},
+	}
+	m.WithClassDefaults(defaults)
+	// The class attribute is read into Type.
+	checkProps(t, m.ObjectByID(1).Properties, map[string]string{"speed": "2", "hostile": "true"})
+	// Properties of the object override the defaults of its class.
+	checkProps(t, m.ObjectByID(2).Properties, map[string]string{"speed": "5", "hostile": "true"})
+	// Objects of classes without defaults are left untouched.
+	checkProps(t, m.ObjectByID(3).Properties, map[string]string{})
+	// Objects of nested object layers are included.
+	checkProps(t, m.ObjectByID(4).Properties, map[string]string{"speed": "2", "hostile": "true"})
+	// The defaults are copied, not shared between objects.
+	m.ObjectByID(1).Properties[0].Value = "9"
+	if got := defaults["enemy"][0].Value; got != "2" {
+		t.Errorf("default property mismatch; expected %q, got %q", "2", got)
+	}
+	if got, _ := m.ObjectByID(4).Properties.Get("speed"); got != "2" {
+		t.Errorf("property mismatch; expected %q, got %q", "2", got)
+	}
+}
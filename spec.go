@@ -1,5 +1,13 @@
 package tmx
 
+// The fields of the types below are declared in the order in which Tiled 1.10
+// writes the corresponding attributes (see MapWriter in src/libtiled of Tiled),
+// so that xml.Marshal writes attributes in the same order as Tiled.
+//
+// Note: The child elements of a map are not written in the order of the tmx
+// file, as the layers are stored by kind (e.g. Layers and ObjectLayers) and the
+// layers of group layers are moved to the map.
+
 // Flip flags stored in the highest three bits of the GID.
 const (
 	FlagDiagonalFlip   = 0x20000000
@@ -16,9 +24,12 @@ const (
 type Map struct {
 	// The TMX format version, generally 1.0.
 	Version string `xml:"version,attr"`
-	// Map orientation. Tiled supports "orthogonal" and "isometric" at the
-	// moment.
+	// Map orientation. Tiled supports "orthogonal", "isometric", "staggered"
+	// and "hexagonal" at the moment.
 	Orientation string `xml:"orientation,attr"`
+	// The order in which tiles are rendered; "right-down" (default),
+	// "right-up", "left-down" or "left-up".
+	RenderOrder string `xml:"renderorder,attr"`
 	// The map width (cols) in tiles.
 	Width int `xml:"width,attr"`
 	// The map height (rows) in tiles.
@@ -27,27 +38,81 @@ type Map struct {
 	TileWidth int `xml:"tilewidth,attr"`
 	// The height in pixels of a tile.
 	TileHeight int `xml:"tileheight,attr"`
+	// StaggerAxis specifies which axis is staggered, "x" or "y". Only used by
+	// staggered and hexagonal maps.
+	StaggerAxis string `xml:"staggeraxis,attr"`
+	// StaggerIndex specifies whether the "even" or "odd" indices along the
+	// staggered axis are shifted. Only used by staggered and hexagonal maps.
+	StaggerIndex string `xml:"staggerindex,attr"`
+	// Infinite specifies whether the map has infinite dimensions, in which case
+	// the tile layer data is stored in chunks. The width and height of infinite
+	// maps may be 0.
+	Infinite bool `xml:"infinite,attr"`
+	// NextLayerID stores the next available ID for new layers.
+	NextLayerID int `xml:"nextlayerid,attr"`
+	// NextObjectID stores the next available ID for new objects.
+	NextObjectID int `xml:"nextobjectid,attr"`
+	// Settings used by the editor, such as the chunk size of infinite maps.
+	EditorSettings EditorSettings `xml:"editorsettings"`
 	// Properties associated with the map.
-	Properties []Property `xml:"properties>property"`
+	//
+	// Note: The properties are collected regardless of the position of the
+	// <properties> element among the children of the <map> element.
+	Properties Properties `xml:"properties>property"`
 	// Tilesets associated with the map.
 	Tilesets []Tileset `xml:"tileset"`
 	// Layers associated with the map.
 	Layers []Layer `xml:"layer"`
 	// Object layers associated with the map.
 	ObjectLayers []ObjectLayer `xml:"objectgroup"`
+	// Image layers associated with the map.
+	//
+	// Note: Image layers are parsed, but not yet taken into account by the
+	// layer order (see Layer.Index).
+	ImageLayers []ImageLayer `xml:"imagelayer"`
+	// Group layers associated with the map.
+	//
+	// Note: The tile and object layers of group layers are stored in Layers and
+	// ObjectLayers respectively, and nested group layers are stored in Groups.
+	Groups []GroupLayer `xml:"group"`
+	// Warnings contains the errors recovered from while parsing the map in
+	// lenient mode (see WithLenient).
+	Warnings ErrorList `xml:"-"`
 }
 
+// Properties is a list of properties.
+//
+// Note: The properties of every <properties> element of the parent element are
+// collected, in document order. This handles tools which emit several
+// <properties> elements for the same element.
+type Properties []Property
+
 // A Property is a name, value pair.
 type Property struct {
 	// The name of the property.
 	Name string `xml:"name,attr"`
+	// The type of the property; "string" (default if empty), "int", "float",
+	// "bool", "color", "file" or "object".
+	Type string `xml:"type,attr"`
 	// The value of the property.
 	Value string `xml:"value,attr"`
 }
 
-/// ### [ todo ] ###
-///    - Source: load info from TSX files.
-/// ### [/ todo ] ###
+// EditorSettings contains settings used by the editor.
+type EditorSettings struct {
+	// The size of the chunks in which the tile layer data of infinite maps is
+	// stored. The size is zero if not specified, in which case Tiled defaults
+	// to 16x16 tiles.
+	ChunkSize ChunkSize `xml:"chunksize"`
+}
+
+// ChunkSize specifies the size of the chunks of infinite maps.
+type ChunkSize struct {
+	// The width of the chunks in tiles.
+	Width int `xml:"width,attr"`
+	// The height of the chunks in tiles.
+	Height int `xml:"height,attr"`
+}
 
 // A Tileset is a sprite sheet of tiles.
 type Tileset struct {
@@ -69,14 +134,28 @@ type Tileset struct {
 	Spacing int `xml:"spacing,attr"`
 	// The margin around the tiles in the tileset (applies to the tileset image).
 	Margin int `xml:"margin,attr"`
+	// The number of tiles in the tileset.
+	TileCount int `xml:"tilecount,attr"`
+	// FillMode specifies how tiles are drawn when they are rendered at a size
+	// which differs from their own, e.g. tile objects which have been resized;
+	// "stretch" or "preserve-aspect-fit". An empty fill mode is equivalent to
+	// "stretch".
+	FillMode string `xml:"fillmode,attr"`
 	// Tile offset associated with the tileset.
 	TileOffset TileOffset `xml:"tileoffset"`
 	// Properties associated with the tileset.
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// The image associated with the tileset.
-	Image Image `xml:"image"`
+	//
+	// Note: If the tileset contains several <image> elements, Image refers to
+	// the first one. All images are available through Images.
+	Image Image `xml:"-"`
+	// Images contains every <image> element of the tileset, in document order.
+	Images []Image `xml:"image"`
 	// TilesInfo contains information about the tiles within a tileset.
 	TilesInfo []TileInfo `xml:"tile"`
+	// Wang sets associated with the tileset.
+	WangSets []WangSet `xml:"wangsets>wangset"`
 }
 
 // A TileOffset specifies an offset in pixels, to be applied when drawing a tile
@@ -91,6 +170,8 @@ type TileOffset struct {
 // An Image is associated with each tileset. It is cut into smaller tiles based
 // on the attributes defined in the tileset.
 type Image struct {
+	// Format specifies the format of embedded image data, e.g. "png".
+	Format string `xml:"format,attr"`
 	// Source refers to the tileset image file.
 	Source string `xml:"source,attr"`
 	// Trans defines a specific color that is treated as transparent (example
@@ -101,37 +182,134 @@ type Image struct {
 	Width int `xml:"width,attr"`
 	// The image height in pixels (optional).
 	Height int `xml:"height,attr"`
+	// Data contains the embedded image data of images without a Source.
+	Data *ImageData `xml:"data"`
+}
+
+// ImageData contains the data of an image which is embedded in the tmx file.
+type ImageData struct {
+	// Encoding specifies the encoding method used for the RawData, generally
+	// "base64".
+	Encoding string `xml:"encoding,attr"`
+	// Compression specifies the compression method used for the RawData. Options
+	// include "gzip", "zlib" and "" for no compression.
+	Compression string `xml:"compression,attr"`
+	// RawData contains the encoded image data.
+	RawData string `xml:",chardata"`
 }
 
 // TileInfo contains information about a tile within a tileset.
 type TileInfo struct {
 	// The local tile ID within its tileset.
 	ID int `xml:"id,attr"`
+	// The class of the tile, e.g. "water" or "wall". Read from the "class"
+	// attribute, or the "type" attribute used by older versions of Tiled.
+	Class string `xml:"class,attr"`
+	// The relative probability of the tile being chosen when placing random
+	// tiles, default value 1.0.
+	Probability float64 `xml:"probability,attr"`
 	// Properties associated with the tile.
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
+	// The image of the tile in tilesets which are collections of images, or nil
+	// if the tile is part of the tileset image.
+	Image *Image `xml:"image"`
+	// Animation of the tile, which has no frames if the tile is not animated.
+	Animation Animation `xml:"animation"`
+}
+
+// An Animation is a sequence of frames, which is played in a loop.
+type Animation struct {
+	// Frames of the animation, in the order they are played.
+	Frames []Frame `xml:"frame"`
+}
+
+// A Frame is a single frame of an animation.
+type Frame struct {
+	// The local tile ID within the parent tileset of the tile displayed during
+	// the frame.
+	TileID int `xml:"tileid,attr"`
+	// The duration of the frame in milliseconds.
+	Duration int `xml:"duration,attr"`
 }
 
-/// ### [ todo ] ###
-///   - Visible: default value true
-///   - Opacity: default value 1.0
-/// ### [/ todo ] ###
+// A WangSet defines a set of Wang tiles, which are used for automatic tiling
+// based on the colors of the corners and edges of the tiles.
+type WangSet struct {
+	// The name of the Wang set.
+	Name string `xml:"name,attr"`
+	// The type of the Wang set; "corner", "edge" or "mixed".
+	Type string `xml:"type,attr"`
+	// The local tile ID of the tile representing the Wang set, or -1.
+	Tile int `xml:"tile,attr"`
+	// Properties associated with the Wang set.
+	Properties Properties `xml:"properties>property"`
+	// Wang colors associated with the Wang set. The color indices of Wang tiles
+	// refer to these colors, starting at 1.
+	WangColors []WangColor `xml:"wangcolor"`
+	// Wang tiles associated with the Wang set.
+	WangTiles []WangTile `xml:"wangtile"`
+}
+
+// A WangColor is a color of a Wang set, e.g. a terrain type.
+type WangColor struct {
+	// The name of the Wang color.
+	Name string `xml:"name,attr"`
+	// The color of the Wang color in "#AARRGGBB" or "#RRGGBB" format.
+	Color string `xml:"color,attr"`
+	// The local tile ID of the tile representing the Wang color, or -1.
+	Tile int `xml:"tile,attr"`
+	// The relative probability of the Wang color being chosen, default value
+	// 1.0.
+	Probability float64 `xml:"probability,attr"`
+	// Properties associated with the Wang color.
+	Properties Properties `xml:"properties>property"`
+}
+
+// A WangTile associates a tile with the Wang colors of its corners and edges.
+type WangTile struct {
+	// The local tile ID of the tile.
+	TileID int `xml:"tileid,attr"`
+	// WangID contains the Wang color indices of the tile, starting at the top
+	// edge and proceeding clockwise: top, top-right, right, bottom-right,
+	// bottom, bottom-left, left and top-left. A color index of 0 denotes no
+	// color.
+	WangID [8]int `xml:"-"`
+}
 
 // A Layer contains information about which global tile ID any given coordinate
 // has. A Map can contain any number of layers.
 type Layer struct {
+	// The unique ID of the layer.
+	ID int `xml:"id,attr"`
 	// The name of the layer.
 	Name string `xml:"name,attr"`
-	// Visible specifies whether the layer is shown (true) or hidden (false).
+	// The width and height of the layer in tiles. The dimensions of the map are
+	// used if the attributes are not present. Layers of infinite maps are
+	// stored in chunks, and their dimensions are informational.
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+	// Visible specifies whether the layer is shown (true) or hidden (false),
+	// default value true.
 	Visible bool `xml:"visible,attr"`
-	// The opacity of the layer as a value from 0.0 to 1.0.
+	// Locked specifies whether the layer is locked for editing in Tiled.
+	Locked bool `xml:"locked,attr"`
+	// The opacity of the layer as a value from 0.0 to 1.0, default value 1.0.
 	Opacity float64 `xml:"opacity,attr"`
 	// Properties associated with the layer.
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// Data contains the information about the tile GIDs associated with a layer.
 	//
 	// Note: Data should not be accessed directly. Use the GetGID method instead
 	// to obtain the GID at a given coordinate.
 	Data *Data `xml:"data"`
+	// Index specifies the position of the layer among the tile and object
+	// layers of the map, in document order.
+	Index int `xml:"-"`
+	// Group is the group layer which contains the layer, or nil if the layer is
+	// not part of a group.
+	Group *GroupLayer `xml:"-"`
+	// offset is the position of the layer in the tmx file.
+	offset int64
 }
 
 // GID corresponds to a global tile ID.
@@ -152,14 +330,46 @@ type Data struct {
 	Encoding string `xml:"encoding,attr"`
 	// Compression specifies the compression method used for the RawData. Options
 	// include "gzip", "zlib" and "" for no compression.
+	//
+	// Note: The encoding and compression are converted to lowercase when the
+	// data is decoded.
 	Compression string `xml:"compression,attr"`
 	// RawData contains the raw data of tile GIDs, which can be represented in
 	// several different ways as specified by Encoding and Compression.
 	RawData string `xml:",innerxml"`
 	// Tiles associated with the layer.
 	Tiles []Tile `xml:"tile"`
+	// Chunks contains the tile GIDs of layers of infinite maps.
+	Chunks []Chunk `xml:"chunk"`
 	// gids contains the decoded tile GIDs arranged by col and row.
 	gids [][]GID
+	// lazy decodes the tile GIDs of a layer which was not decoded while
+	// parsing (see WithLayers), or is nil if the layer was decoded while
+	// parsing.
+	lazy *lazyDecode
+	// infinite specifies whether the tile GIDs are stored in chunks, as the
+	// layer belongs to an infinite map.
+	infinite bool
+}
+
+// A Chunk contains the tile GIDs of a rectangular region of a layer, in
+// infinite maps. The encoding and compression of the chunk is specified by the
+// parent Data.
+type Chunk struct {
+	// The x coordinate of the chunk in tiles.
+	X int `xml:"x,attr"`
+	// The y coordinate of the chunk in tiles.
+	Y int `xml:"y,attr"`
+	// The width of the chunk in tiles.
+	Width int `xml:"width,attr"`
+	// The height of the chunk in tiles.
+	Height int `xml:"height,attr"`
+	// RawData contains the raw data of tile GIDs of the chunk.
+	RawData string `xml:",innerxml"`
+	// Tiles associated with the chunk.
+	Tiles []Tile `xml:"tile"`
+	// grid holds the tile GIDs of the chunk, which are decoded on first access.
+	grid *chunkGrid
 }
 
 // A Tile contains the GID of a single tile on a tile layer.
@@ -168,9 +378,59 @@ type Tile struct {
 	GID GID `xml:"gid,attr"`
 }
 
-/// ### [ todo ] ###
-///   - Visible: default value true
-/// ### [/ todo ] ###
+// An ImageLayer displays a single image.
+type ImageLayer struct {
+	// The unique ID of the image layer.
+	ID int `xml:"id,attr"`
+	// The name of the image layer.
+	Name string `xml:"name,attr"`
+	// Visible specifies whether the image layer is shown (true) or hidden
+	// (false), default value true.
+	Visible bool `xml:"visible,attr"`
+	// The opacity of the image layer as a value from 0.0 to 1.0, default value
+	// 1.0.
+	Opacity float64 `xml:"opacity,attr"`
+	// Horizontal rendering offset of the image in pixels.
+	OffsetX int `xml:"offsetx,attr"`
+	// Vertical rendering offset of the image in pixels.
+	OffsetY int `xml:"offsety,attr"`
+	// Properties associated with the image layer.
+	Properties Properties `xml:"properties>property"`
+	// The image of the image layer.
+	Image Image `xml:"image"`
+}
+
+// A GroupLayer groups tile layers, object layers and other group layers. The
+// visibility and opacity of a group layer apply to every layer it contains.
+type GroupLayer struct {
+	// The unique ID of the group layer.
+	ID int `xml:"id,attr"`
+	// The name of the group layer.
+	Name string `xml:"name,attr"`
+	// Visible specifies whether the group layer is shown (true) or hidden
+	// (false), default value true.
+	Visible bool `xml:"visible,attr"`
+	// The opacity of the group layer as a value from 0.0 to 1.0, default value
+	// 1.0.
+	Opacity float64 `xml:"opacity,attr"`
+	// Properties associated with the group layer.
+	Properties Properties `xml:"properties>property"`
+	// Parent is the group layer which contains the group layer, or nil if the
+	// group layer is not nested.
+	Parent *GroupLayer `xml:"-"`
+	// layers contains the tile layers of the group layer until they are moved
+	// to the map.
+	layers []Layer
+	// objectLayers contains the object layers of the group layer until they are
+	// moved to the map.
+	objectLayers []ObjectLayer
+	// imageLayers contains the image layers of the group layer until they are
+	// moved to the map.
+	imageLayers []ImageLayer
+	// groups contains the nested group layers of the group layer until they are
+	// moved to the map.
+	groups []GroupLayer
+}
 
 // An ObjectLayer contains information about different objects on the map. A Map
 // can contain any number of object layers.
@@ -181,14 +441,32 @@ type Tile struct {
 // and size in pixels, but you can still easily align that to the grid when you
 // want to.
 type ObjectLayer struct {
+	// The unique ID of the object layer.
+	ID int `xml:"id,attr"`
 	// The name of the object layer.
 	Name string `xml:"name,attr"`
-	// Visible specifies whether the layer is shown (true) or hidden (false).
+	// Visible specifies whether the layer is shown (true) or hidden (false),
+	// default value true.
 	Visible bool `xml:"visible,attr"`
-	// The opacity of the layer as a value from 0.0 to 1.0.
+	// The opacity of the layer as a value from 0.0 to 1.0, default value 1.0.
 	Opacity float64 `xml:"opacity,attr"`
+	// Horizontal rendering offset of the objects in pixels.
+	OffsetX int `xml:"offsetx,attr"`
+	// Vertical rendering offset of the objects in pixels.
+	OffsetY int `xml:"offsety,attr"`
+	// The order in which objects are drawn; "topdown" (default) sorts objects
+	// by their y coordinate, and "index" uses the document order.
+	DrawOrder string `xml:"draworder,attr"`
 	// Objects associated with the object layer.
 	Objects []Object `xml:"object"`
+	// Index specifies the position of the object layer among the tile and
+	// object layers of the map, in document order.
+	Index int `xml:"-"`
+	// Group is the group layer which contains the object layer, or nil if the
+	// object layer is not part of a group.
+	Group *GroupLayer `xml:"-"`
+	// offset is the position of the object layer in the tmx file.
+	offset int64
 }
 
 // An Object can be positioned anywhere on the map, and is not necessarily
@@ -197,32 +475,47 @@ type ObjectLayer struct {
 // You generally use objects to add custom information to your tile map, such
 // as spawn points, warps, exits, etc.
 type Object struct {
+	// The unique ID of the object.
+	ID int `xml:"id,attr"`
 	// The name of the object.
 	Name string `xml:"name,attr"`
-	// The type of the object.
+	// The type of the object. Read from the "type" attribute, or the "class"
+	// attribute used by newer versions of Tiled.
 	Type string `xml:"type,attr"`
-	// The x coordinate of the object in pixels.
-	X int `xml:"x,attr"`
-	// The y coordinate of the object in pixels.
-	Y int `xml:"y,attr"`
-	// The width of the object in pixels.
-	Width int `xml:"width,attr"`
-	// The height of the object in pixels.
-	Height int `xml:"height,attr"`
 	// GID is a reference to a global tile ID.
 	//
 	// When the object has a GID set, then it is represented by the image of the
-	// tile with that global tile ID. Currently that means Width and Height are
-	// ignored for such objects. The image alignment currently depends on the map
+	// tile with that global tile ID. Width and Height are ignored for such
+	// objects, which have the tile size of their tileset instead (see
+	// Map.ObjectPixelSize). The image alignment currently depends on the map
 	// orientation. In orthogonal orientation it's aligned to the bottom-left
 	// while in isometric it's aligned to the bottom-center.
 	GID GID `xml:"gid,attr"`
+	// The x coordinate of the object in pixels, which may be fractional.
+	X float64 `xml:"x,attr"`
+	// The y coordinate of the object in pixels, which may be fractional.
+	Y float64 `xml:"y,attr"`
+	// The width of the object in pixels, which may be fractional.
+	Width float64 `xml:"width,attr"`
+	// The height of the object in pixels, which may be fractional.
+	Height float64 `xml:"height,attr"`
+	// The rotation of the object in degrees clockwise around (X, Y).
+	Rotation float64 `xml:"rotation,attr"`
+	// Visible specifies whether the object is shown (true) or hidden (false),
+	// default value true.
+	Visible bool `xml:"visible,attr"`
 	// Properties associated with the object.
-	Properties []Property `xml:"properties>property"`
+	//
+	// Note: The properties are parsed regardless of whether the <properties>
+	// element precedes or follows the shape of the object (e.g. <polygon>).
+	Properties Properties `xml:"properties>property"`
 	// A Polygon associated with the object.
 	Polygon Polygon `xml:"polygon"`
 	// A Polyline associated with the object.
 	Polyline Polyline `xml:"polyline"`
+	// Text associated with the object, or nil if the object is not a text
+	// object.
+	Text *Text `xml:"text"`
 }
 
 // A Polygon object is made up of a space-delimited list of x,y coordinates. The
@@ -242,3 +535,27 @@ type Polyline struct {
 	// Points contains a list of x,y coordinates in pixels.
 	Points string `xml:"points,attr"`
 }
+
+// A Text object displays text within the bounds of its parent object.
+type Text struct {
+	// The font family used, default value "sans-serif".
+	FontFamily string `xml:"fontfamily,attr"`
+	// The size of the font in pixels, default value 16.
+	PixelSize int `xml:"pixelsize,attr"`
+	// Wrap specifies whether word wrapping is enabled.
+	Wrap bool `xml:"wrap,attr"`
+	// The color of the text in "#AARRGGBB" or "#RRGGBB" format, default value
+	// "#000000".
+	Color string `xml:"color,attr"`
+	// Bold specifies whether the font is bold.
+	Bold bool `xml:"bold,attr"`
+	// Italic specifies whether the font is italic.
+	Italic bool `xml:"italic,attr"`
+	// Horizontal alignment of the text; "left" (default), "center", "right" or
+	// "justify".
+	HAlign string `xml:"halign,attr"`
+	// Vertical alignment of the text; "top" (default), "center" or "bottom".
+	VAlign string `xml:"valign,attr"`
+	// The text to display.
+	Text string `xml:",chardata"`
+}
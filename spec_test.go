@@ -0,0 +1,133 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAttributeOrder(t *testing.T) {
+	// The test map is hand-written, based on the attribute order of Tiled 1.10.
+	f, err := os.Open("testdata/attribute_order.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	m, err := NewFile(io.TeeReader(f, &buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := attributeOrder(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := xml.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := attributeOrder(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The root element is named after the Map type.
+	got["map"] = got["Map"]
+	for elem, wantAttrs := range want {
+		gotAttrs, ok := got[elem]
+		if !ok {
+			t.Errorf("<%s> element missing from output", elem)
+			continue
+		}
+		// Compare the order of the attributes present in both, as Tiled omits
+		// attributes with default values, and some attributes are not yet
+		// supported.
+		w := filterAttrs(wantAttrs, gotAttrs)
+		g := filterAttrs(gotAttrs, wantAttrs)
+		if !equalStrings(w, g) {
+			t.Errorf("<%s> attribute order mismatch; expected %v, got %v", elem, w, g)
+		}
+	}
+}
+
+// attributeOrder returns the attribute names of the first element of each name
+// in the XML document, in the order they are written.
+func attributeOrder(buf []byte) (map[string][]string, error) {
+	order := make(map[string][]string)
+	d := xml.NewDecoder(bytes.NewReader(buf))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return order, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if _, ok := order[start.Name.Local]; ok {
+			continue
+		}
+		attrs := []string{}
+		for _, attr := range start.Attr {
+			attrs = append(attrs, attr.Name.Local)
+		}
+		order[start.Name.Local] = attrs
+	}
+}
+
+// filterAttrs returns the attribute names of a which are also present in b.
+func filterAttrs(a, b []string) []string {
+	var filtered []string
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				filtered = append(filtered, x)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// equalStrings reports whether a and b contain the same strings in the same
+// order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEditorSettingsChunkSize(t *testing.T) {
+	golden := []struct {
+		settings string
+		want     ChunkSize
+	}{
+		{settings: `<editorsettings><chunksize width="32" height="8"/></editorsettings>`, want: ChunkSize{Width: 32, Height: 8}},
+		// Other editor settings are ignored.
+		{settings: `<editorsettings><export target="out.json" format="json"/></editorsettings>`},
+		// The chunk size is zero if not specified.
+		{settings: ""},
+	}
+	for _, g := range golden {
+		src := `<map version="1.2" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32" infinite="1">` + g.settings + `</map>`
+		m, err := NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%q: unexpected error; %v", g.settings, err)
+			continue
+		}
+		if got := m.EditorSettings.ChunkSize; got != g.want {
+			t.Errorf("%q: chunk size mismatch; expected %v, got %v", g.settings, g.want, got)
+		}
+	}
+}
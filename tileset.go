@@ -0,0 +1,236 @@
+package tmx
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TilesetForGID returns the tileset which contains the given global tile ID, or
+// nil if no such tileset exists. The flip flags must be cleared from gid.
+func (m *Map) TilesetForGID(gid int) *Tileset {
+	if gid == 0 {
+		// GID 0 denotes an empty tile.
+		return nil
+	}
+	var found *Tileset
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		if ts.FirstGID > gid {
+			continue
+		}
+		if found == nil || found.FirstGID < ts.FirstGID {
+			found = ts
+		}
+	}
+	if found != nil && found.TileCount > 0 && gid >= found.FirstGID+found.TileCount {
+		// gid is located past the last tile of the tileset.
+		return nil
+	}
+	return found
+}
+
+// Validate verifies that the global tile ID ranges of the tilesets are valid
+// and don't overlap. The range of a tileset is determined by its tile count,
+// which for external tilesets is only known once the TSX file has been loaded
+// (see Open).
+//
+// Tilesets whose tile size differs from the tile size of the map are not
+// considered invalid, as this is usually intentional (e.g. tall tiles) but
+// sometimes a mistake; use TileSizeMismatches to list them.
+func (m *Map) Validate() error {
+	var tilesets []*Tileset
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		if ts.FirstGID < 1 {
+			return fmt.Errorf("Validate: invalid first GID %d of tileset '%s'.", ts.FirstGID, ts.Name)
+		}
+		tilesets = append(tilesets, ts)
+	}
+	sort.Sort(byFirstGID(tilesets))
+	for i := 1; i < len(tilesets); i++ {
+		prev, ts := tilesets[i-1], tilesets[i]
+		if prev.FirstGID == ts.FirstGID || prev.TileCount > 0 && prev.FirstGID+prev.TileCount > ts.FirstGID {
+			return fmt.Errorf("Validate: GID range of tileset '%s' overlaps with tileset '%s'.", prev.Name, ts.Name)
+		}
+	}
+	return nil
+}
+
+// TileSizeMismatches returns an informational error for each tileset whose tile
+// size differs from the tile size of the map, in the order the tilesets are
+// declared. Renderers which align tiles to the map grid misplace the tiles of
+// such tilesets.
+func (m *Map) TileSizeMismatches() ErrorList {
+	var errs ErrorList
+	for _, ts := range m.Tilesets {
+		if ts.TileWidth != m.TileWidth || ts.TileHeight != m.TileHeight {
+			errs = append(errs, fmt.Errorf("TileSizeMismatches: tile size %dx%d of tileset '%s' differs from the tile size %dx%d of the map.", ts.TileWidth, ts.TileHeight, ts.Name, m.TileWidth, m.TileHeight))
+		}
+	}
+	return errs
+}
+
+// byFirstGID implements sort.Interface, sorting tilesets by first GID.
+type byFirstGID []*Tileset
+
+func (s byFirstGID) Len() int           { return len(s) }
+func (s byFirstGID) Less(i, j int) bool { return s[i].FirstGID < s[j].FirstGID }
+func (s byFirstGID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// UsedTilesets returns the tilesets which contain at least one of the global
+// tile IDs referenced by the tile layers or tile objects of the map. The
+// tilesets are returned in the order they are declared in the map.
+func (m *Map) UsedTilesets() []*Tileset {
+	used := make(map[*Tileset]bool)
+	mark := func(gid int) {
+		if ts := m.TilesetForGID(gid); ts != nil {
+			used[ts] = true
+		}
+	}
+	for i := range m.Layers {
+		m.Layers[i].eachRawGID(func(col, row int, gid GID) {
+			mark(gid.GlobalTileID())
+		})
+	}
+	for _, ol := range m.ObjectLayers {
+		for _, o := range ol.Objects {
+			mark(o.GID.GlobalTileID())
+		}
+	}
+	var tilesets []*Tileset
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		if used[ts] {
+			tilesets = append(tilesets, ts)
+		}
+	}
+	return tilesets
+}
+
+// tileInfo returns the information about the tile with the given local tile ID,
+// or nil if the tileset has no information about the tile.
+func (ts *Tileset) tileInfo(id int) *TileInfo {
+	for i := range ts.TilesInfo {
+		if ts.TilesInfo[i].ID == id {
+			return &ts.TilesInfo[i]
+		}
+	}
+	return nil
+}
+
+// TileClass returns the class of the tile at a given coordinate of the tile
+// layer with the given index in m.Layers, or "" if the tile has no class or the
+// index is invalid.
+func (m *Map) TileClass(layerIndex, col, row int) string {
+	if layerIndex < 0 || layerIndex >= len(m.Layers) {
+		return ""
+	}
+	gid := m.Layers[layerIndex].GetGID(col, row)
+	ts := m.TilesetForGID(gid)
+	if ts == nil {
+		return ""
+	}
+	if t := ts.tileInfo(gid - ts.FirstGID); t != nil {
+		return t.Class
+	}
+	return ""
+}
+
+// TileAt returns the tileset, local tile ID and flip flags of the tile at a
+// given coordinate of the tile layer with the given index in m.Layers. Note
+// that the index is not the Index of the layer, which is its position among all
+// layers of the map. The boolean result ok is false if the index is invalid,
+// the cell is empty or the tile belongs to no tileset.
+func (m *Map) TileAt(layerIndex, col, row int) (ts *Tileset, localID int, h, v, d bool, ok bool) {
+	if layerIndex < 0 || layerIndex >= len(m.Layers) {
+		return nil, 0, false, false, false, false
+	}
+	gid := m.Layers[layerIndex].GetRawGID(col, row)
+	ts = m.TilesetForGID(gid.GlobalTileID())
+	if ts == nil {
+		return nil, 0, false, false, false, false
+	}
+	localID = gid.GlobalTileID() - ts.FirstGID
+	return ts, localID, gid.IsHorizontalFlip(), gid.IsVerticalFlip(), gid.IsDiagonalFlip(), true
+}
+
+// TilesetInfo summarizes a tileset of a map (see TilesetReport).
+type TilesetInfo struct {
+	// The name of the tileset.
+	Name string
+	// The TSX file of external tilesets, or "" for embedded tilesets.
+	Source string
+	// The first global tile ID of the tileset.
+	FirstGID int
+	// The last global tile ID of the tileset, or 0 if unknown.
+	LastGID int
+	// The (maximum) width of the tiles in the tileset.
+	TileWidth int
+	// The (maximum) height of the tiles in the tileset.
+	TileHeight int
+	// External specifies whether the tileset is stored in a TSX file.
+	External bool
+	// Collection specifies whether the tileset is a collection of images,
+	// rather than a single tileset image.
+	Collection bool
+}
+
+// TilesetReport returns a summary of each tileset of the map, including the
+// range of global tile IDs it covers, in the order the tilesets are declared.
+func (m *Map) TilesetReport() []TilesetInfo {
+	var infos []TilesetInfo
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		info := TilesetInfo{
+			Name:       ts.Name,
+			Source:     ts.Source,
+			FirstGID:   ts.FirstGID,
+			TileWidth:  ts.TileWidth,
+			TileHeight: ts.TileHeight,
+			External:   ts.Source != "",
+			Collection: ts.isCollection(),
+		}
+		if n := ts.tileCount(); n > 0 {
+			info.LastGID = ts.FirstGID + n - 1
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// isCollection returns true if the tileset is a collection of images, rather
+// than a single tileset image.
+func (ts *Tileset) isCollection() bool {
+	if len(ts.Images) > 0 {
+		return false
+	}
+	for _, t := range ts.TilesInfo {
+		if t.Image != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// tileCount returns the number of tiles of the tileset, or 0 if unknown. The
+// count is determined by the tile count of the tileset if present, and
+// otherwise by the tile IDs of collections or the size of the tileset image.
+func (ts *Tileset) tileCount() int {
+	if ts.TileCount > 0 {
+		return ts.TileCount
+	}
+	if ts.isCollection() {
+		n := 0
+		for _, t := range ts.TilesInfo {
+			n = max(n, t.ID+1)
+		}
+		return n
+	}
+	img := ts.Image
+	if img.Width == 0 || img.Height == 0 || ts.TileWidth <= 0 || ts.TileHeight <= 0 {
+		return 0
+	}
+	cols := (img.Width - 2*ts.Margin + ts.Spacing) / (ts.TileWidth + ts.Spacing)
+	rows := (img.Height - 2*ts.Margin + ts.Spacing) / (ts.TileHeight + ts.Spacing)
+	return cols * rows
+}
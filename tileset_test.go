@@ -0,0 +1,494 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTilesetMultipleImages(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="multi" tilewidth="32" tileheight="32">
+  <image source="first.png" width="64" height="64"/>
+  <image source="second.png" width="128" height="128"/>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := m.Tilesets[0]
+	if got, want := ts.Image.Source, "first.png"; got != want {
+		t.Errorf("image source mismatch; expected %q, got %q", want, got)
+	}
+	if got, want := len(ts.Images), 2; got != want {
+		t.Fatalf("number of images mismatch; expected %d, got %d", want, got)
+	}
+	if got, want := ts.Images[1].Source, "second.png"; got != want {
+		t.Errorf("second image source mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestTilesetUnmarshal(t *testing.T) {
+	// The image is set when decoding a tileset directly, e.g. a TSX file.
+	const src = `
+<tileset name="tsx" tilewidth="32" tileheight="32">
+ <image source="sheet.png" width="64" height="64"/>
+</tileset>`
+	var ts Tileset
+	err := xml.Unmarshal([]byte(src), &ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ts.Image.Source, "sheet.png"; got != want {
+		t.Errorf("image source mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestMapUsedTilesetsChunked(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := m.UsedTilesets()
+	if len(used) != 1 {
+		t.Fatalf("number of used tilesets mismatch; expected 1, got %d", len(used))
+	}
+	if got, want := used[0].Name, "b"; got != want {
+		t.Errorf("used tileset mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestMapTilesetForGID(t *testing.T) {
+	m, err := NewFile(strings.NewReader(chunkedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		gid  int
+		want string
+	}{
+		{gid: 0, want: ""},
+		{gid: 1, want: "a"},
+		{gid: 4, want: "a"},
+		{gid: 5, want: "b"},
+		{gid: 8, want: "b"},
+		// Past the last tile of the last tileset.
+		{gid: 9, want: ""},
+	}
+	for _, g := range golden {
+		var got string
+		if ts := m.TilesetForGID(g.gid); ts != nil {
+			got = ts.Name
+		}
+		if got != g.want {
+			t.Errorf("gid=%d: tileset mismatch; expected %q, got %q", g.gid, g.want, got)
+		}
+	}
+}
+
+func TestMapUsedTilesets(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32" tilecount="4"/>
+ <tileset firstgid="5" name="b" tilewidth="32" tileheight="32" tilecount="4"/>
+ <tileset firstgid="9" name="c" tilewidth="32" tileheight="32" tilecount="4"/>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">0,2147483657</data>
+ </layer>
+ <objectgroup name="objects">
+  <object id="1" gid="2" x="0" y="32"/>
+ </objectgroup>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GID 2147483657 is GID 9 flipped horizontally.
+	var got []string
+	for _, ts := range m.UsedTilesets() {
+		got = append(got, ts.Name)
+	}
+	want := []string{"a", "c"}
+	if !equalStrings(got, want) {
+		t.Errorf("used tilesets mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestTileInfoProbability(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="tiles" tilewidth="32" tileheight="32">
+  <tile id="0" probability="0.25"/>
+  <tile id="1"/>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles := m.Tilesets[0].TilesInfo
+	if got, want := tiles[0].Probability, 0.25; got != want {
+		t.Errorf("probability mismatch; expected %v, got %v", want, got)
+	}
+	// The default probability is 1.
+	if got, want := tiles[1].Probability, 1.0; got != want {
+		t.Errorf("default probability mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestMapValidate(t *testing.T) {
+	golden := []struct {
+		tilesets string
+		valid    bool
+	}{
+		{
+			tilesets: `
+ <tileset firstgid="5" name="b" tilewidth="32" tileheight="32" tilecount="4"/>
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32" tilecount="4"/>`,
+			valid: true,
+		},
+		// Overlapping GID ranges, declared in any order.
+		{
+			tilesets: `
+ <tileset firstgid="4" name="b" tilewidth="32" tileheight="32" tilecount="4"/>
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32" tilecount="4"/>`,
+			valid: false,
+		},
+		// Same first GID, with unknown tile counts.
+		{
+			tilesets: `
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32"/>
+ <tileset firstgid="1" name="b" tilewidth="32" tileheight="32"/>`,
+			valid: false,
+		},
+		// Invalid first GID.
+		{
+			tilesets: `
+ <tileset firstgid="0" name="a" tilewidth="32" tileheight="32" tilecount="4"/>`,
+			valid: false,
+		},
+	}
+	for i, g := range golden {
+		src := `<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">` + g.tilesets + `</map>`
+		m, err := NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("i=%d: unexpected error; %v", i, err)
+			continue
+		}
+		err = m.Validate()
+		if g.valid && err != nil {
+			t.Errorf("i=%d: unexpected validation error; %v", i, err)
+		}
+		if !g.valid && err == nil {
+			t.Errorf("i=%d: expected validation error", i)
+		}
+	}
+}
+
+func TestTilesetEmbeddedImage(t *testing.T) {
+	// The image data is "hello" compressed using zlib.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="embedded" tilewidth="32" tileheight="32">
+  <image format="png" width="32" height="32">
+   <data encoding="base64" compression="zlib">
+    eJzLSM3JyQcABiwCFQ==
+   </data>
+  </image>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := m.Tilesets[0].Image
+	if img.Format != "png" || img.Source != "" || img.Data == nil {
+		t.Fatalf("image mismatch; got format %q, source %q and data %v", img.Format, img.Source, img.Data)
+	}
+	buf, err := img.Data.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "hello"; got != want {
+		t.Errorf("image data mismatch; expected %q, got %q", want, got)
+	}
+	if _, err := (&ImageData{Encoding: "csv", RawData: "1,2"}).Bytes(); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+}
+
+func TestMapTileClass(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="4" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="terrain" tilewidth="32" tileheight="32" tilecount="4">
+  <tile id="0" class="water"/>
+  <tile id="1" type="wall"/>
+  <tile id="2" class="grass" type="wall"/>
+ </tileset>
+ <layer name="ground" width="4" height="1">
+  <data encoding="csv">1,2,2147483651,4</data>
+ </layer>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Tilesets[0].Name, "terrain"; got != want {
+		t.Errorf("tileset name mismatch; expected %q, got %q", want, got)
+	}
+	// The legacy type attribute is used if the class attribute is missing.
+	want := []string{"water", "wall", "grass", ""}
+	for col := range want {
+		if got := m.TileClass(0, col, 0); got != want[col] {
+			t.Errorf("class of tile at column %d mismatch; expected %q, got %q", col, want[col], got)
+		}
+	}
+	// Invalid layer indices are treated like empty cells.
+	for _, layerIndex := range []int{-1, 1} {
+		if got := m.TileClass(layerIndex, 0, 0); got != "" {
+			t.Errorf("class of tile in layer %d mismatch; expected \"\", got %q", layerIndex, got)
+		}
+	}
+}
+
+func TestTilesetWangSets(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="terrain" tilewidth="32" tileheight="32" tilecount="4">
+  <wangsets>
+   <wangset name="ground" type="corner" tile="2">
+    <wangcolor name="grass" color="#00ff00" tile="0" probability="0.5"/>
+    <wangcolor name="sand" color="#ffff00"/>
+    <wangtile tileid="0" wangid="0,1,0,2,0,1,0,2"/>
+    <wangtile tileid="1" wangid="0x10203040"/>
+   </wangset>
+  </wangsets>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets := m.Tilesets[0].WangSets
+	if len(sets) != 1 {
+		t.Fatalf("number of Wang sets mismatch; expected 1, got %d", len(sets))
+	}
+	ws := sets[0]
+	if ws.Name != "ground" || ws.Type != "corner" || ws.Tile != 2 {
+		t.Errorf("Wang set mismatch; got name %q, type %q and tile %d", ws.Name, ws.Type, ws.Tile)
+	}
+	if len(ws.WangTiles) != 2 {
+		t.Fatalf("number of Wang tiles mismatch; expected 2, got %d", len(ws.WangTiles))
+	}
+	want := [8]int{0, 1, 0, 2, 0, 1, 0, 2}
+	if got := ws.WangTiles[0].WangID; got != want {
+		t.Errorf("Wang ID mismatch; expected %v, got %v", want, got)
+	}
+	// Older versions of Tiled store one color index per 4 bits, starting with
+	// the least significant bits.
+	want = [8]int{0, 4, 0, 3, 0, 2, 0, 1}
+	if got := ws.WangTiles[1].WangID; got != want {
+		t.Errorf("Wang ID mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestParseWangIDInvalid(t *testing.T) {
+	for _, s := range []string{"", "0,1,0,1", "0,1,0,1,0,1,0,1,0", "0,1,0,x,0,1,0,1", "0xZZ"} {
+		if _, err := parseWangID(s); err == nil {
+			t.Errorf("%q: expected error for invalid Wang ID", s)
+		}
+	}
+}
+
+func TestMapTileAt(t *testing.T) {
+	m, err := NewFile(strings.NewReader(`
+<map version="1.0" orientation="orthogonal" width="3" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32" tilecount="4"/>
+ <tileset firstgid="5" name="b" tilewidth="32" tileheight="32" tilecount="4"/>
+ <layer name="ground" width="3" height="1">
+  <data encoding="csv">2,3221225479,0</data>
+ </layer>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []struct {
+		col     int
+		ts      string
+		localID int
+		h, v, d bool
+		ok      bool
+	}{
+		{col: 0, ts: "a", localID: 1, ok: true},
+		// GID 7 flipped horizontally and vertically.
+		{col: 1, ts: "b", localID: 2, h: true, v: true, ok: true},
+		{col: 2, ok: false},
+		// Outside of the layer.
+		{col: 3, ok: false},
+	}
+	for _, g := range golden {
+		ts, localID, h, v, d, ok := m.TileAt(0, g.col, 0)
+		if ok != g.ok {
+			t.Errorf("col=%d: ok mismatch; expected %v, got %v", g.col, g.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ts.Name != g.ts || localID != g.localID || h != g.h || v != g.v || d != g.d {
+			t.Errorf("col=%d: tile mismatch; expected %s:%d (h=%v, v=%v, d=%v), got %s:%d (h=%v, v=%v, d=%v)", g.col, g.ts, g.localID, g.h, g.v, g.d, ts.Name, localID, h, v, d)
+		}
+	}
+	// Invalid layer indices are reported by ok.
+	for _, layerIndex := range []int{-1, 1} {
+		if _, _, _, _, _, ok := m.TileAt(layerIndex, 0, 0); ok {
+			t.Errorf("layer %d: expected ok to be false for invalid layer index", layerIndex)
+		}
+	}
+}
+
+func TestTilesetFillMode(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="a" tilewidth="32" tileheight="32" fillmode="preserve-aspect-fit"/>
+ <tileset firstgid="2" name="b" tilewidth="32" tileheight="32"/>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Tilesets[0].FillMode, "preserve-aspect-fit"; got != want {
+		t.Errorf("fill mode mismatch; expected %q, got %q", want, got)
+	}
+	// An empty fill mode is equivalent to "stretch".
+	if got := m.Tilesets[1].FillMode; got != "" {
+		t.Errorf("fill mode mismatch; expected empty fill mode, got %q", got)
+	}
+}
+
+func TestTilesetWangColors(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="terrain" tilewidth="32" tileheight="32" tilecount="4">
+  <wangsets>
+   <wangset name="ground" type="corner" tile="-1">
+    <wangcolor name="grass" color="#00ff00" tile="0" probability="0.5">
+     <properties>
+      <property name="walkable" type="bool" value="true"/>
+     </properties>
+    </wangcolor>
+    <wangcolor name="sand" color="#ffff00"/>
+   </wangset>
+  </wangsets>
+ </tileset>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := m.Tilesets[0].WangSets[0].WangColors
+	golden := []WangColor{
+		{Name: "grass", Color: "#00ff00", Tile: 0, Probability: 0.5},
+		// Absent attributes have their default values.
+		{Name: "sand", Color: "#ffff00", Tile: -1, Probability: 1},
+	}
+	if len(colors) != len(golden) {
+		t.Fatalf("number of Wang colors mismatch; expected %d, got %d", len(golden), len(colors))
+	}
+	for i, want := range golden {
+		got := colors[i]
+		if got.Name != want.Name || got.Color != want.Color || got.Tile != want.Tile || got.Probability != want.Probability {
+			t.Errorf("Wang color %d mismatch; expected %+v, got %+v", i+1, want, got)
+		}
+	}
+	checkProps(t, colors[0].Properties, map[string]string{"walkable": "true"})
+	checkProps(t, colors[1].Properties, map[string]string{})
+}
+
+func TestMapTilesetReport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="counted" tilewidth="32" tileheight="32" tilecount="6"/>
+ <tileset firstgid="7" source="tiles/ext.tsx"/>
+ <tileset firstgid="20" name="sheet" tilewidth="16" tileheight="16" margin="1" spacing="2">
+  <image source="sheet.png" width="54" height="36"/>
+ </tileset>
+ <tileset firstgid="40" name="collection" tilewidth="64" tileheight="48">
+  <tile id="0">
+   <image source="a.png" width="64" height="48"/>
+  </tile>
+  <tile id="4">
+   <image source="b.png" width="32" height="32"/>
+  </tile>
+ </tileset>
+ <tileset firstgid="50" name="unknown" tilewidth="32" tileheight="32"/>
+</map>`,
+		"tiles/ext.tsx": `
+<tileset name="ext" tilewidth="32" tileheight="32" tilecount="10">
+ <image source="ext.png" width="64" height="160"/>
+</tileset>`,
+	})
+	m, err := Open(filepath.Join(dir, "map.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := []TilesetInfo{
+		{Name: "counted", FirstGID: 1, LastGID: 6, TileWidth: 32, TileHeight: 32},
+		{Name: "ext", Source: "tiles/ext.tsx", FirstGID: 7, LastGID: 16, TileWidth: 32, TileHeight: 32, External: true},
+		// 3 columns and 2 rows of tiles, with a margin of 1 and a spacing of 2
+		// pixels.
+		{Name: "sheet", FirstGID: 20, LastGID: 25, TileWidth: 16, TileHeight: 16},
+		// The tile count of collections is determined by the highest tile ID.
+		{Name: "collection", FirstGID: 40, LastGID: 44, TileWidth: 64, TileHeight: 48, Collection: true},
+		{Name: "unknown", FirstGID: 50, TileWidth: 32, TileHeight: 32},
+	}
+	got := m.TilesetReport()
+	if len(got) != len(golden) {
+		t.Fatalf("number of tilesets mismatch; expected %d, got %d", len(golden), len(got))
+	}
+	for i, want := range golden {
+		if got[i] != want {
+			t.Errorf("tileset %q mismatch; expected %+v, got %+v", want.Name, want, got[i])
+		}
+	}
+}
+
+func TestMapTileSizeMismatches(t *testing.T) {
+	const src = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="same" tilewidth="32" tileheight="32" tilecount="4"/>
+ <tileset firstgid="5" name="tall" tilewidth="32" tileheight="64" tilecount="4"/>
+ <tileset firstgid="9" name="small" tilewidth="16" tileheight="32" tilecount="4"/>
+</map>`
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Differing tile sizes are not errors, and don't modify the map.
+	if err := m.Validate(); err != nil {
+		t.Fatalf("unexpected validation error; %v", err)
+	}
+	if len(m.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", []error(m.Warnings))
+	}
+	want := []string{
+		"TileSizeMismatches: tile size 32x64 of tileset 'tall' differs from the tile size 32x32 of the map.",
+		"TileSizeMismatches: tile size 16x32 of tileset 'small' differs from the tile size 32x32 of the map.",
+	}
+	var got []string
+	for _, err := range m.TileSizeMismatches() {
+		got = append(got, err.Error())
+	}
+	if !equalStrings(got, want) {
+		t.Errorf("tile size mismatches mismatch; expected %q, got %q", want, got)
+	}
+	// Tilesets matching the tile size of the map are not reported.
+	m.Tilesets = m.Tilesets[:1]
+	if errs := m.TileSizeMismatches(); len(errs) != 0 {
+		t.Errorf("expected no tile size mismatches, got %v", []error(errs))
+	}
+}
@@ -6,41 +6,140 @@
 package tmx
 
 import (
+	"bytes"
 	"compress/gzip"
 	"compress/zlib"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/xml"
 	"fmt"
+	"image"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 )
 
 // Open reads the provided tmx file and returns a parsed Map, based on the TMX
-// file format.
-func Open(tmxPath string) (m *Map, err error) {
+// file format. External tilesets are loaded from TSX files located relative to
+// the tmx file.
+func Open(tmxPath string, opts ...Option) (m *Map, err error) {
+	return openFile(tmxPath, newConfig(opts))
+}
+
+// openFile reads the provided tmx file and returns a parsed Map, based on the
+// TMX file format and the given parsing configuration.
+func openFile(tmxPath string, conf *config) (m *Map, err error) {
 	fr, err := os.Open(tmxPath)
 	if err != nil {
 		return nil, err
 	}
 	defer fr.Close()
-	return NewFile(fr)
+	conf.dir = filepath.Dir(tmxPath)
+	return newFile(fr, conf)
+}
+
+// OpenDir reads the tmx files of the provided directory and returns the parsed
+// maps, keyed by file name. Files which fail to parse are skipped, and their
+// errors are returned as an ErrorList once all files have been read.
+func OpenDir(dir string, opts ...Option) (maps map[string]*Map, err error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	maps = make(map[string]*Map)
+	var errs ErrorList
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || strings.ToLower(filepath.Ext(name)) != ".tmx" {
+			continue
+		}
+		m, err := Open(filepath.Join(dir, name), opts...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("OpenDir: unable to open '%s'; %v", name, err))
+			continue
+		}
+		maps[name] = m
+	}
+	if len(errs) > 0 {
+		return maps, errs
+	}
+	return maps, nil
 }
 
 // NewFile reads from the provided io.Reader and returns a parsed Map, based on
 // the TMX file format.
-func NewFile(r io.Reader) (m *Map, err error) {
+//
+// Note: External tilesets are not loaded, as their location is unknown. Use
+// Open to load maps which refer to TSX files.
+func NewFile(r io.Reader, opts ...Option) (m *Map, err error) {
+	return newFile(r, newConfig(opts))
+}
+
+// newFile reads from the provided io.Reader and returns a parsed Map, based on
+// the TMX file format and the given parsing configuration.
+func newFile(r io.Reader, conf *config) (m *Map, err error) {
 	d := xml.NewDecoder(r)
 	m = new(Map)
+	conf.warnings = &m.Warnings
 	err = d.Decode(m)
 	if err != nil {
 		return nil, err
 	}
-	for _, l := range m.Layers {
-		err = l.Data.decode(m.Width, m.Height)
+	if m.Width < 0 || m.Height < 0 {
+		return nil, fmt.Errorf("NewFile: invalid map dimensions %dx%d.", m.Width, m.Height)
+	}
+	m.flattenGroups()
+	m.indexLayers()
+	for i := range m.Tilesets {
+		ts := &m.Tilesets[i]
+		if ts.Source != "" && conf.dir != "" {
+			// Resolve external tilesets before anything relies on their
+			// contents (e.g. the tile count).
+			err = ts.load(conf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(ts.Images) > 0 {
+			// The image sources of external tilesets have been adjusted.
+			ts.Image = ts.Images[0]
+		}
+	}
+	for i := range m.Layers {
+		l := &m.Layers[i]
+		if l.Data == nil {
+			return nil, fmt.Errorf("NewFile: layer '%s' has no data.", l.Name)
+		}
+		if l.Width == 0 {
+			l.Width = m.Width
+		}
+		if l.Height == 0 {
+			l.Height = m.Height
+		}
+		if l.Width < 0 || l.Height < 0 {
+			return nil, fmt.Errorf("NewFile: invalid dimensions %dx%d of layer '%s'.", l.Width, l.Height, l.Name)
+		}
+		data := l.Data
+		data.infinite = m.Infinite
+		cols, rows, infinite := l.Width, l.Height, m.Infinite
+		decode := func() error {
+			if infinite {
+				return data.decodeChunks(conf)
+			}
+			return data.decode(cols, rows, conf)
+		}
+		if !conf.decodeLayer(l.Name) {
+			data.lazy = &lazyDecode{decode: decode}
+			continue
+		}
+		err = decode()
 		if err != nil {
 			return nil, err
 		}
@@ -48,27 +147,313 @@ func NewFile(r io.Reader) (m *Map, err error) {
 	return m, nil
 }
 
+// indexLayers records the document order of the tile and object layers of the
+// map, which is lost as they are stored in separate slices.
+func (m *Map) indexLayers() {
+	i, j := 0, 0
+	for index := 0; i < len(m.Layers) || j < len(m.ObjectLayers); index++ {
+		if j >= len(m.ObjectLayers) || i < len(m.Layers) && m.Layers[i].offset < m.ObjectLayers[j].offset {
+			m.Layers[i].Index = index
+			i++
+		} else {
+			m.ObjectLayers[j].Index = index
+			j++
+		}
+	}
+}
+
+// load loads the contents of an external tileset from the TSX file referred to
+// by Source, which is located relative to the directory of the parsing
+// configuration. The FirstGID and Source of the tileset are preserved, and the
+// image sources of the TSX file are adjusted to be relative to the directory.
+//
+// A TSX file which in turn refers to another TSX file is resolved recursively.
+// An error is returned if the references form a cycle.
+func (ts *Tileset) load(conf *config) error {
+	dir := conf.dir
+	source := ts.Source
+	var stack []string
+	for {
+		tsxPath := filepath.Join(dir, filepath.FromSlash(source))
+		for _, p := range stack {
+			if p == tsxPath {
+				return fmt.Errorf("load: recursive reference to tileset '%s'.", source)
+			}
+		}
+		stack = append(stack, tsxPath)
+		ext, err := conf.readTSX(tsxPath)
+		if err != nil {
+			return err
+		}
+		tsxDir := path.Dir(source)
+		// The parsed tileset may be shared (see Loader), so the images are
+		// copied before being adjusted.
+		ext.Images = append([]Image(nil), ext.Images...)
+		for i := range ext.Images {
+			if ext.Images[i].Source != "" {
+				ext.Images[i].Source = path.Join(tsxDir, ext.Images[i].Source)
+			}
+		}
+		if ext.Source == "" {
+			ext.FirstGID = ts.FirstGID
+			ext.Source = ts.Source
+			*ts = *ext
+			return nil
+		}
+		// The referenced source is relative to the TSX file.
+		source = path.Join(tsxDir, ext.Source)
+	}
+}
+
+// readTSX reads the provided TSX file and returns the parsed tileset.
+func readTSX(tsxPath string) (*Tileset, error) {
+	fr, err := os.Open(tsxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+	ts := new(Tileset)
+	err = xml.NewDecoder(fr).Decode(ts)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// UnmarshalXML decodes a <tileset> element, or the <tileset> element of a TSX
+// file. Image is set to the first <image> element of the tileset.
+func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tileset Tileset
+	var v tileset
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	if len(v.Images) > 0 {
+		v.Image = v.Images[0]
+	}
+	*ts = Tileset(v)
+	return nil
+}
+
+// UnmarshalXML decodes a <tile> element of a tileset, applying the default
+// values of attributes which are not present.
+func (t *TileInfo) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tileInfo TileInfo
+	v := struct {
+		tileInfo
+		// Legacy name of the class attribute.
+		Type string `xml:"type,attr"`
+	}{tileInfo: tileInfo{Probability: 1.0}}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	if v.Class == "" {
+		v.Class = v.Type
+	}
+	*t = TileInfo(v.tileInfo)
+	return nil
+}
+
+// UnmarshalXML decodes a <layer> element, recording its position in the tmx
+// file and applying the default values of attributes which are not present.
+func (l *Layer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type layer Layer
+	v := layer{Visible: true, Opacity: 1}
+	offset := d.InputOffset()
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	v.offset = offset
+	*l = Layer(v)
+	return nil
+}
+
+// UnmarshalXML decodes an <objectgroup> element, recording its position in the
+// tmx file and applying the default values of attributes which are not present.
+func (ol *ObjectLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type objectLayer ObjectLayer
+	v := objectLayer{Visible: true, Opacity: 1}
+	offset := d.InputOffset()
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	v.offset = offset
+	*ol = ObjectLayer(v)
+	return nil
+}
+
+// UnmarshalXML decodes an <imagelayer> element, applying the default values of
+// attributes which are not present.
+func (il *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type imageLayer ImageLayer
+	v := imageLayer{Visible: true, Opacity: 1}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*il = ImageLayer(v)
+	return nil
+}
+
+// UnmarshalXML decodes a <group> element, applying the default values of
+// attributes which are not present.
+func (g *GroupLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type groupLayer GroupLayer
+	v := struct {
+		groupLayer
+		Layers       []Layer       `xml:"layer"`
+		ObjectLayers []ObjectLayer `xml:"objectgroup"`
+		ImageLayers  []ImageLayer  `xml:"imagelayer"`
+		Groups       []GroupLayer  `xml:"group"`
+	}{groupLayer: groupLayer{Visible: true, Opacity: 1}}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*g = GroupLayer(v.groupLayer)
+	g.layers = v.Layers
+	g.objectLayers = v.ObjectLayers
+	g.imageLayers = v.ImageLayers
+	g.groups = v.Groups
+	return nil
+}
+
+// UnmarshalXML decodes an <object> element, applying the default values of
+// attributes which are not present.
+func (o *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type object Object
+	v := struct {
+		object
+		// Newer name of the type attribute.
+		Class string `xml:"class,attr"`
+	}{object: object{Visible: true}}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	if v.Type == "" {
+		v.Type = v.Class
+	}
+	*o = Object(v.object)
+	return nil
+}
+
+// UnmarshalXML decodes a <text> element, applying the default values of
+// attributes which are not present.
+func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type text Text
+	v := text{
+		FontFamily: "sans-serif",
+		PixelSize:  16,
+		Color:      "#000000",
+		HAlign:     "left",
+		VAlign:     "top",
+	}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*t = Text(v)
+	return nil
+}
+
+// UnmarshalXML decodes a <wangcolor> element, applying the default values of
+// attributes which are not present.
+func (wc *WangColor) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangColor WangColor
+	v := wangColor{Tile: -1, Probability: 1.0}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*wc = WangColor(v)
+	return nil
+}
+
+// UnmarshalXML decodes a <wangtile> element, parsing its Wang ID.
+func (wt *WangTile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangTile WangTile
+	var v struct {
+		wangTile
+		RawWangID string `xml:"wangid,attr"`
+	}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	v.WangID, err = parseWangID(v.RawWangID)
+	if err != nil {
+		return err
+	}
+	*wt = WangTile(v.wangTile)
+	return nil
+}
+
+// parseWangID parses a Wang ID, which is either stored as a comma-separated
+// list of eight color indices or as a 32-bit hexadecimal number with one color
+// index per 4 bits (used by older versions of Tiled).
+func parseWangID(s string) (wangID [8]int, err error) {
+	if strings.HasPrefix(s, "0x") {
+		x, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil {
+			return wangID, err
+		}
+		for i := range wangID {
+			wangID[i] = int(x >> (4 * uint(i)) & 0xF)
+		}
+		return wangID, nil
+	}
+	fields := strings.Split(s, ",")
+	if len(fields) != len(wangID) {
+		return wangID, fmt.Errorf("parseWangID: wrong number of color indices in '%s'. Got %d, wanted %d.", s, len(fields), len(wangID))
+	}
+	for i, field := range fields {
+		wangID[i], err = strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return wangID, err
+		}
+	}
+	return wangID, nil
+}
+
 // decode decodes the GIDs that are stored in the <data> XML-tag of a layer. It
 // will handle the various encodings and compression methods.
-func (data *Data) decode(cols, rows int) (err error) {
+func (data *Data) decode(cols, rows int, conf *config) (err error) {
 	if data.gids != nil {
 		// data has already been decoded.
 		return nil
 	}
-	// alloc
-	data.gids = make([][]GID, cols)
-	for i := range data.gids {
-		data.gids[i] = make([]GID, rows)
+	err = checkDims(cols, rows)
+	if err != nil {
+		return err
+	}
+	// Some exporters use uppercase encoding and compression names.
+	data.Encoding = strings.ToLower(strings.TrimSpace(data.Encoding))
+	data.Compression = strings.ToLower(strings.TrimSpace(data.Compression))
+	if data.Encoding == "csv" && conf.externalDataDir != "" {
+		err = data.loadExternal(conf.externalDataDir)
+		if err != nil {
+			return err
+		}
 	}
 	// decode
 	switch data.Encoding {
 	case "base64":
-		err = data.decodeBase64(cols, rows)
+		err = data.decodeBase64(cols, rows, conf)
+		if err != nil && conf.lenient && len(data.Tiles) == cols*rows {
+			// Some broken exporters store the GIDs as <tile> elements.
+			conf.warn(fmt.Errorf("decode: invalid base64 layer data; %v; using <tile> elements instead.", err))
+			err = data.decodeXML(cols, rows)
+		}
 		if err != nil {
 			return err
 		}
 	case "csv":
-		err = data.decodeCsv(cols, rows)
+		err = data.decodeCsv(cols, rows, conf)
 		if err != nil {
 			return err
 		}
@@ -80,65 +465,310 @@ func (data *Data) decode(cols, rows int) (err error) {
 	default:
 		return fmt.Errorf("decodeData: encoding '%s' not yet implemented.", data.Encoding)
 	}
+	return data.checkGrid(cols, rows)
+}
+
+// decodeChunks prepares the decoding of the GIDs that are stored in the <chunk>
+// XML-tags of a layer in an infinite map. The GIDs of each chunk are decoded on
+// first access, unless eager chunk decoding is enabled (see WithEagerChunks).
+func (data *Data) decodeChunks(conf *config) error {
+	for i := range data.Chunks {
+		c := &data.Chunks[i]
+		err := checkDims(c.Width, c.Height)
+		if err != nil {
+			return err
+		}
+		// The encoding and compression of chunks is specified by the parent.
+		chunkData := &Data{
+			Encoding:    data.Encoding,
+			Compression: data.Compression,
+			RawData:     c.RawData,
+			Tiles:       c.Tiles,
+		}
+		cols, rows := c.Width, c.Height
+		c.grid = &chunkGrid{
+			decode: func() ([][]GID, error) {
+				err := chunkData.decode(cols, rows, conf)
+				return chunkData.gids, err
+			},
+		}
+		if conf.eagerChunks {
+			if _, err := c.gids(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chunkGrid holds the GIDs of a chunk, which are decoded on first access.
+type chunkGrid struct {
+	// once guards the decoding of the GIDs.
+	once sync.Once
+	// decode decodes the GIDs of the chunk.
+	decode func() ([][]GID, error)
+	// gids contains the decoded tile GIDs arranged by col and row, relative to
+	// the chunk.
+	gids [][]GID
+	// err is the error encountered while decoding the GIDs, if any.
+	err error
+}
+
+// gids returns the GIDs of the chunk arranged by col and row, relative to the
+// chunk. The GIDs are decoded on first access, and it is safe to call gids
+// concurrently.
+func (c *Chunk) gids() ([][]GID, error) {
+	if c.grid == nil {
+		return nil, nil
+	}
+	c.grid.once.Do(func() {
+		c.grid.gids, c.grid.err = c.grid.decode()
+		c.grid.decode = nil
+	})
+	return c.grid.gids, c.grid.err
+}
+
+// chunked returns true if the tile GIDs are stored in chunks rather than in a
+// grid, which is the case for layers of infinite maps. Infinite maps with no
+// chunks have no grid either.
+func (data *Data) chunked() bool {
+	return data.infinite || len(data.Chunks) > 0
+}
+
+// chunkGID returns the raw global tile ID at a given coordinate of a layer in
+// an infinite map. The empty GID 0 is returned for coordinates outside of the
+// chunks.
+func (data *Data) chunkGID(col, row int) (GID, error) {
+	for i := range data.Chunks {
+		c := &data.Chunks[i]
+		if col >= c.X && col < c.X+c.Width && row >= c.Y && row < c.Y+c.Height {
+			gids, err := c.gids()
+			if err != nil {
+				return 0, err
+			}
+			return gids[col-c.X][row-c.Y], nil
+		}
+	}
+	return 0, nil
+}
+
+// setChunkGID sets the raw global tile ID at a given coordinate of a layer in
+// an infinite map. It panics if the coordinate is outside of the chunks.
+func (data *Data) setChunkGID(col, row int, gid GID) {
+	for i := range data.Chunks {
+		c := &data.Chunks[i]
+		if col >= c.X && col < c.X+c.Width && row >= c.Y && row < c.Y+c.Height {
+			gids, err := c.gids()
+			if err != nil {
+				panic(fmt.Sprintf("tmx.Data.setChunkGID: unable to decode chunk; %v", err))
+			}
+			gids[col-c.X][row-c.Y] = gid
+			return
+		}
+	}
+	panic(fmt.Sprintf("tmx.Data.setChunkGID: coordinate (%d, %d) is outside of the chunks", col, row))
+}
+
+// loadExternal replaces the raw data with the contents of the external file it
+// refers to, if any. The path of the external file is relative to dir.
+func (data *Data) loadExternal(dir string) error {
+	name := strings.TrimSpace(data.RawData)
+	if !strings.HasSuffix(strings.ToLower(name), ".csv") || strings.ContainsAny(name, ", \t\r\n") || filepath.IsAbs(name) {
+		// Not a reference to an external file.
+		return nil
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	data.RawData = string(buf)
+	return nil
+}
+
+const (
+	// maxDimension is the maximum number of columns or rows of a GID grid.
+	maxDimension = 1 << 16
+	// maxTiles is the maximum number of tiles of a GID grid, which bounds the
+	// memory allocated for malformed input.
+	maxTiles = 1 << 26
+)
+
+// checkDims verifies that a GID grid of cols columns and rows rows is within the
+// supported limits, before any memory is allocated for the grid.
+func checkDims(cols, rows int) error {
+	if cols < 0 || rows < 0 {
+		return fmt.Errorf("checkDims: invalid dimensions %dx%d.", cols, rows)
+	}
+	if cols > maxDimension || rows > maxDimension {
+		return fmt.Errorf("checkDims: dimensions %dx%d exceed the maximum of %d columns and rows.", cols, rows, maxDimension)
+	}
+	// Divide rather than multiply, to prevent overflow.
+	if rows != 0 && cols > maxTiles/rows {
+		return fmt.Errorf("checkDims: dimensions %dx%d exceed the maximum of %d tiles.", cols, rows, maxTiles)
+	}
+	return nil
+}
+
+// alloc allocates the GID grid of cols columns and rows rows. It should only be
+// called once the number of GIDs has been verified, to prevent malformed input
+// from causing excessive allocations.
+func (data *Data) alloc(cols, rows int) {
+	data.gids = make([][]GID, cols)
+	for i := range data.gids {
+		data.gids[i] = make([]GID, rows)
+	}
+}
+
+// checkGrid verifies that the decoded GIDs form a complete grid of cols
+// columns, each containing rows entries.
+func (data *Data) checkGrid(cols, rows int) error {
+	if len(data.gids) != cols {
+		return fmt.Errorf("checkGrid: wrong number of columns. Got %d, wanted %d.", len(data.gids), cols)
+	}
+	for col, gids := range data.gids {
+		if len(gids) != rows {
+			return fmt.Errorf("checkGrid: wrong number of rows in column %d. Got %d, wanted %d.", col, len(gids), rows)
+		}
+	}
 	return nil
 }
 
 // decodeBase64 decodes the GIDs that are stored as a base64-encoded array of
 // unsigned 32-bit integers, using little-endian byte ordering. This array may
 // be compressed using gzip or zlib.
-func (data *Data) decodeBase64(cols, rows int) (err error) {
-	s := strings.TrimSpace(data.RawData)
-	r := base64.NewDecoder(base64.StdEncoding, strings.NewReader(s))
-	switch data.Compression {
+func (data *Data) decodeBase64(cols, rows int, conf *config) (err error) {
+	buf, err := decodeBase64String(data.RawData)
+	if err != nil {
+		return err
+	}
+	compression := data.Compression
+	if compression == "" && !conf.strictCompression && len(buf) != 4*cols*rows {
+		// Some exporters omit the compression attribute of compressed data.
+		compression = sniffCompression(buf)
+	}
+	// Limit the decompressed size to one more byte than required, which is
+	// enough to detect superfluous data.
+	limit := 4*cols*rows + 1
+	raw := buf
+	buf, err = decompress(raw, compression, limit)
+	if err != nil && conf.lenient {
+		// Retry using the compression method identified by the magic bytes, as
+		// some exporters mislabel the compression.
+		if sniffed := sniffCompression(raw); sniffed != "" && sniffed != compression {
+			buf, err = decompress(raw, sniffed, limit)
+			if err == nil {
+				conf.warn(fmt.Errorf("decodeBase64: layer data declared as '%s' compressed, but compressed using '%s'.", compression, sniffed))
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// We should have one GID for each tile.
+	if len(buf) != 4*cols*rows {
+		return fmt.Errorf("decodeBase64: wrong number of GIDs. Got %d bytes, wanted %d.", len(buf), 4*cols*rows)
+	}
+	data.alloc(cols, rows)
+	i := 0
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			gid := binary.LittleEndian.Uint32(buf[i*4:])
+			data.gids[col][row] = GID(gid)
+			i++
+		}
+	}
+	return nil
+}
+
+// decodeBase64String decodes the base64-encoded string s, which may be either
+// padded or unpadded. Whitespace is ignored, including line breaks within the
+// data (e.g. CRLF line endings).
+func decodeBase64String(s string) ([]byte, error) {
+	s = strings.Map(stripSpace, s)
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		// Some exporters omit the padding.
+		if buf, err := base64.RawStdEncoding.DecodeString(s); err == nil {
+			return buf, nil
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// stripSpace removes whitespace runes.
+func stripSpace(r rune) rune {
+	if unicode.IsSpace(r) {
+		// skip rune.
+		return -1
+	}
+	return r
+}
+
+// decompress decompresses buf using the given compression method ("gzip",
+// "zlib" or "" for no compression). At most limit bytes are decompressed.
+func decompress(buf []byte, compression string, limit int) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(buf)
+	switch compression {
 	case "gzip":
 		z, err := gzip.NewReader(r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer z.Close()
 		r = z
 	case "zlib":
 		z, err := zlib.NewReader(r)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer z.Close()
 		r = z
 	case "": // no compression.
-		break
+		return buf, nil
 	default:
-		return fmt.Errorf("decodeBase64: compression '%s' not yet implemented.", data.Compression)
+		return nil, fmt.Errorf("decompress: compression '%s' not yet implemented.", compression)
 	}
-	buf, err := ioutil.ReadAll(r)
-	// We should have one GID for each tile.
-	if len(buf)/4 != cols*rows {
-		return fmt.Errorf("decodeBase64: wrong number of GIDs. Got %d, wanted %d.", len(buf)/4, cols*rows)
+	return ioutil.ReadAll(io.LimitReader(r, int64(limit)))
+}
+
+// sniffCompression returns the compression method ("gzip", "zlib" or "")
+// identified by the magic bytes at the start of buf.
+func sniffCompression(buf []byte) string {
+	if len(buf) < 2 {
+		return ""
 	}
-	i := 0
-	for row := 0; row < rows; row++ {
-		for col := 0; col < cols; col++ {
-			gid := binary.LittleEndian.Uint32(buf[i*4:])
-			data.gids[col][row] = GID(gid)
-			i++
-		}
+	switch {
+	case buf[0] == 0x1F && buf[1] == 0x8B:
+		return "gzip"
+	case buf[0]&0x0F == 8 && (uint(buf[0])<<8|uint(buf[1]))%31 == 0:
+		// zlib header: deflate compression method with a valid check sum.
+		return "zlib"
 	}
-	return nil
+	return ""
 }
 
-// decodeCvs decodes the GIDs that are stored as comma-separated values.
-func (data *Data) decodeCsv(cols, rows int) (err error) {
+// decodeCvs decodes the GIDs that are stored as comma-separated values. Empty
+// values are ignored, which allows for trailing commas and rows separated only
+// by newlines.
+func (data *Data) decodeCsv(cols, rows int, conf *config) (err error) {
 	cleanData := strings.Map(clean, data.RawData)
-	rawGIDs := strings.Split(cleanData, ",")
+	rawGIDs := strings.FieldsFunc(cleanData, isComma)
 	// We should have one GID for each tile.
 	if len(rawGIDs) != cols*rows {
 		return fmt.Errorf("decodeCsv: wrong number of GIDs. Got %d, wanted %d.", len(rawGIDs), cols*rows)
 	}
+	data.alloc(cols, rows)
 	i := 0
 	for row := 0; row < rows; row++ {
 		for col := 0; col < cols; col++ {
-			gid, err := strconv.Atoi(rawGIDs[i])
+			gid, err := strconv.ParseUint(rawGIDs[i], 10, 32)
 			if err != nil {
-				return err
+				if !conf.lenient {
+					return err
+				}
+				conf.warn(fmt.Errorf("decodeCsv: invalid GID '%s' at (%d, %d) replaced by 0.", rawGIDs[i], col, row))
+				gid = 0
 			}
 			data.gids[col][row] = GID(gid)
 			i++
@@ -147,21 +777,31 @@ func (data *Data) decodeCsv(cols, rows int) (err error) {
 	return nil
 }
 
-// clean cleans the csv data from superfluous runes.
+// clean cleans the csv data from superfluous runes. Whitespace is treated as a
+// separator.
 func clean(r rune) rune {
 	if r >= '0' && r <= '9' || r == ',' {
 		return r
 	}
+	if unicode.IsSpace(r) {
+		return ','
+	}
 	// skip rune.
 	return -1
 }
 
+// isComma reports whether r is a comma.
+func isComma(r rune) bool {
+	return r == ','
+}
+
 // decodeXML decodes the GIDs that are stored in the <tile> XML-tags' 'gid'
 // attribute.
 func (data *Data) decodeXML(cols, rows int) (err error) {
 	if len(data.Tiles) != cols*rows {
 		return fmt.Errorf("decodeXML: wrong number of GIDs. Got %d, wanted %d.", len(data.Tiles), cols*rows)
 	}
+	data.alloc(cols, rows)
 	i := 0
 	for row := 0; row < rows; row++ {
 		for col := 0; col < cols; col++ {
@@ -172,16 +812,127 @@ func (data *Data) decodeXML(cols, rows int) (err error) {
 	return nil
 }
 
+// Bytes returns the decoded contents of the embedded image data, in the format
+// specified by the Format of the image (e.g. PNG).
+func (data *ImageData) Bytes() ([]byte, error) {
+	if data.Encoding != "base64" {
+		return nil, fmt.Errorf("ImageData.Bytes: encoding '%s' not yet implemented.", data.Encoding)
+	}
+	buf, err := decodeBase64String(data.RawData)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(buf, data.Compression, math.MaxInt32)
+}
+
+// Decode decodes the GIDs of a layer which was not decoded while parsing (see
+// WithLayers), and the GIDs of every chunk of a layer in an infinite map, which
+// are otherwise decoded on first access (see WithEagerChunks). Decoding a layer
+// which has already been decoded is a no-op.
+func (l *Layer) Decode() error {
+	err := l.decodeData()
+	if err != nil {
+		return err
+	}
+	for i := range l.Data.Chunks {
+		_, err := l.Data.Chunks[i].gids()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeData decodes the GIDs of a layer which was not decoded while parsing
+// (see WithLayers). The GIDs are decoded once, and it is safe to call
+// decodeData concurrently.
+func (l *Layer) decodeData() error {
+	if l.Data == nil || l.Data.lazy == nil {
+		return nil
+	}
+	lazy := l.Data.lazy
+	lazy.once.Do(func() {
+		lazy.err = lazy.decode()
+		lazy.decode = nil
+	})
+	return lazy.err
+}
+
+// lazyDecode decodes the GIDs of a layer on first access.
+type lazyDecode struct {
+	// once guards the decoding of the GIDs.
+	once sync.Once
+	// decode decodes the GIDs of the layer.
+	decode func() error
+	// err is the error encountered while decoding the GIDs, if any.
+	err error
+}
+
+// decoded ensures that the GIDs of the layer have been decoded. It panics if
+// decoding fails.
+func (l *Layer) decoded() {
+	err := l.decodeData()
+	if err != nil {
+		panic(fmt.Sprintf("tmx.Layer.decoded: unable to decode layer '%s'; %v", l.Name, err))
+	}
+}
+
 // GetGID returns the global tile ID at a given coordinate, after clearing the
 // flip flags.
+//
+// The coordinates of layers in infinite maps may be negative (see
+// OriginOffset). Layers and chunks which were not decoded while parsing (see
+// WithLayers and WithEagerChunks) are decoded on demand, and GetGID panics if
+// decoding fails.
 func (l *Layer) GetGID(col, row int) int {
-	return l.Data.gids[col][row].GlobalTileID()
+	return l.GetRawGID(col, row).GlobalTileID()
 }
 
 // GetRawGID returns the global tile ID at a given coordinate, without clearing
-// the flip flags.
+// the flip flags. The empty GID 0 is returned for coordinates outside of the
+// layer, e.g. for layers which are smaller than the map.
+//
+// The coordinates of layers in infinite maps may be negative (see
+// OriginOffset). Layers and chunks which were not decoded while parsing (see
+// WithLayers and WithEagerChunks) are decoded on demand, and GetRawGID panics if
+// decoding fails.
 func (l *Layer) GetRawGID(col, row int) GID {
-	return l.Data.gids[col][row]
+	l.decoded()
+	if l.Data.chunked() {
+		gid, err := l.Data.chunkGID(col, row)
+		if err != nil {
+			panic(fmt.Sprintf("tmx.Layer.GetRawGID: unable to decode chunk of layer '%s'; %v", l.Name, err))
+		}
+		return gid
+	}
+	gids := l.Data.gids
+	if col < 0 || col >= len(gids) || row < 0 || row >= len(gids[col]) {
+		return 0
+	}
+	return gids[col][row]
+}
+
+// GetGIDAt returns the global tile ID at the coordinate p, where p.X is the
+// column and p.Y is the row, after clearing the flip flags.
+func (l *Layer) GetGIDAt(p image.Point) int {
+	return l.GetGID(p.X, p.Y)
+}
+
+// GetRawGIDAt returns the global tile ID at the coordinate p, where p.X is the
+// column and p.Y is the row, without clearing the flip flags.
+func (l *Layer) GetRawGIDAt(p image.Point) GID {
+	return l.GetRawGID(p.X, p.Y)
+}
+
+// SetRawGID sets the global tile ID at a given coordinate, including the flip
+// flags.
+func (l *Layer) SetRawGID(col, row int, gid GID) {
+	l.decoded()
+	if l.Data.chunked() {
+		l.Data.setChunkGID(col, row, gid)
+		return
+	}
+	l.Data.gids[col][row] = gid
 }
 
 // GlobalTileID returns the GID after clearing the flip flags.
@@ -220,3 +971,25 @@ func (gid GID) IsFlip() bool {
 	}
 	return false
 }
+
+// MakeGID returns the GID of the given global tile ID, with the provided flip
+// flags set.
+func MakeGID(id int, h, v, d bool) GID {
+	return GID(id).WithFlip(h, v, d)
+}
+
+// WithFlip returns the GID with its flip flags replaced by the provided flip
+// flags.
+func (gid GID) WithFlip(h, v, d bool) GID {
+	gid &^= FlagFlip
+	if h {
+		gid |= FlagHorizontalFlip
+	}
+	if v {
+		gid |= FlagVerticalFlip
+	}
+	if d {
+		gid |= FlagDiagonalFlip
+	}
+	return gid
+}
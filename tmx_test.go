@@ -0,0 +1,427 @@
+package tmx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataCheckGrid(t *testing.T) {
+	golden := []struct {
+		gids  [][]GID
+		valid bool
+	}{
+		{gids: [][]GID{{1, 2}, {3, 4}, {5, 6}}, valid: true},
+		// Missing column.
+		{gids: [][]GID{{1, 2}, {3, 4}}, valid: false},
+		// Short column.
+		{gids: [][]GID{{1, 2}, {3}, {5, 6}}, valid: false},
+		// Long column.
+		{gids: [][]GID{{1, 2}, {3, 4}, {5, 6, 7}}, valid: false},
+	}
+	for i, g := range golden {
+		data := &Data{gids: g.gids}
+		err := data.checkGrid(3, 2)
+		if g.valid && err != nil {
+			t.Errorf("i=%d: unexpected error; %v", i, err)
+		}
+		if !g.valid && err == nil {
+			t.Errorf("i=%d: expected error for incomplete grid", i)
+		}
+	}
+}
+
+func TestDataDecodeGrid(t *testing.T) {
+	m, err := Open("testdata/test_csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range m.Layers {
+		if err := l.Data.checkGrid(l.Width, l.Height); err != nil {
+			t.Errorf("layer %q: %v", l.Name, err)
+		}
+	}
+	// A layer with too few GIDs is rejected.
+	const src = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="2" height="2">
+  <data encoding="csv">1,2,3</data>
+ </layer>
+</map>`
+	if _, err := NewFile(strings.NewReader(src)); err == nil {
+		t.Error("expected error for layer with too few GIDs")
+	}
+}
+
+// layerMapSource returns the source of a 2x2 map with a single tile layer,
+// whose data element has the given attributes and contents.
+func layerMapSource(attrs, data string) string {
+	const format = `
+<map version="1.0" orientation="orthogonal" width="2" height="2" tilewidth="32" tileheight="32">
+ <layer name="ground" width="2" height="2">
+  <data %s>%s</data>
+ </layer>
+</map>`
+	return fmt.Sprintf(format, attrs, data)
+}
+
+// checkGIDs reports an error if the GIDs of the first layer of the map differ
+// from want, in row-major order.
+func checkGIDs(t *testing.T, m *Map, want []int) {
+	got := m.Layers[0].GIDsRowMajor()
+	if len(got) != len(want) {
+		t.Errorf("number of GIDs mismatch; expected %d, got %d", len(want), len(got))
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GIDs mismatch; expected %v, got %v", want, got)
+			return
+		}
+	}
+}
+
+func TestDataSniffCompression(t *testing.T) {
+	gids := []uint32{1, 2, 3, 4}
+	for _, compression := range []string{"gzip", "zlib"} {
+		// The compression attribute is omitted.
+		src := layerMapSource(`encoding="base64"`, encodeGIDs(gids, "base64", compression))
+		m, err := NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%s: unexpected error; %v", compression, err)
+			continue
+		}
+		checkGIDs(t, m, []int{1, 2, 3, 4})
+		if _, err := NewFile(strings.NewReader(src), WithStrictCompression()); err == nil {
+			t.Errorf("%s: expected error in strict compression mode", compression)
+		}
+	}
+}
+
+func TestDataDecodeCsv(t *testing.T) {
+	golden := []string{
+		"1,2,3,4",
+		// Trailing comma.
+		"1,2,\n3,4,\n",
+		// Rows separated only by newlines.
+		"1,2\n3,4",
+		// CRLF line endings and indentation.
+		"\r\n  1,2,\r\n  3,4\r\n",
+		// Whitespace-separated values.
+		"1 2\t3 4",
+	}
+	for _, data := range golden {
+		m, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, data)))
+		if err != nil {
+			t.Errorf("%q: unexpected error; %v", data, err)
+			continue
+		}
+		checkGIDs(t, m, []int{1, 2, 3, 4})
+	}
+	// Empty values are not GIDs.
+	if _, err := NewFile(strings.NewReader(layerMapSource(`encoding="csv"`, "1,,2,3"))); err == nil {
+		t.Error("expected error for too few GIDs")
+	}
+}
+
+// writeFiles writes the provided files, keyed by slash-separated path, to a new
+// temporary directory and returns the path of the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestOpenExternalTileset(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="tilesets/dungeon.tsx"/>
+ <tileset firstgid="5" name="inline" tilewidth="32" tileheight="32" tilecount="4"/>
+ <layer name="ground" width="1" height="1">
+  <data encoding="csv">2</data>
+ </layer>
+</map>`,
+		"tilesets/dungeon.tsx": `
+<tileset name="dungeon" tilewidth="32" tileheight="32" tilecount="4">
+ <image source="../images/dungeon.png" width="64" height="64"/>
+</tileset>`,
+	})
+	m, err := Open(filepath.Join(dir, "map.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := m.Tilesets[0]
+	if ts.Name != "dungeon" || ts.FirstGID != 1 || ts.Source != "tilesets/dungeon.tsx" || ts.TileCount != 4 {
+		t.Errorf("tileset mismatch; got name %q, first GID %d, source %q and tile count %d", ts.Name, ts.FirstGID, ts.Source, ts.TileCount)
+	}
+	// The image source is relative to the tmx file.
+	if got, want := ts.Image.Source, "images/dungeon.png"; got != want {
+		t.Errorf("image source mismatch; expected %q, got %q", want, got)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("unexpected validation error; %v", err)
+	}
+	if got := m.TilesetForGID(2); got == nil || got.Name != "dungeon" {
+		t.Errorf("tileset of GID 2 mismatch; expected %q, got %v", "dungeon", got)
+	}
+}
+
+func TestOpenExternalTilesetMissing(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="missing.tsx"/>
+</map>`,
+	})
+	if _, err := Open(filepath.Join(dir, "map.tmx")); err == nil {
+		t.Error("expected error for missing TSX file")
+	}
+}
+
+func TestOpenRecursiveTileset(t *testing.T) {
+	const mapSrc = `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="a.tsx"/>
+</map>`
+	golden := []map[string]string{
+		// Self-reference.
+		{"map.tmx": mapSrc, "a.tsx": `<tileset source="a.tsx"/>`},
+		// Indirect reference, relative to the referring TSX file.
+		{
+			"map.tmx":   mapSrc,
+			"a.tsx":     `<tileset source="sub/b.tsx"/>`,
+			"sub/b.tsx": `<tileset source="../a.tsx"/>`,
+		},
+	}
+	for i, files := range golden {
+		dir := writeFiles(t, files)
+		if _, err := Open(filepath.Join(dir, "map.tmx")); err == nil {
+			t.Errorf("i=%d: expected error for recursive tileset reference", i)
+		}
+	}
+}
+
+func TestOpenNestedTileset(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"map.tmx": `
+<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" source="a.tsx"/>
+</map>`,
+		"a.tsx": `<tileset source="sub/b.tsx"/>`,
+		"sub/b.tsx": `
+<tileset name="b" tilewidth="32" tileheight="32" tilecount="4">
+ <image source="b.png" width="64" height="64"/>
+</tileset>`,
+	})
+	m, err := Open(filepath.Join(dir, "map.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := m.Tilesets[0]
+	if ts.Name != "b" || ts.FirstGID != 1 || ts.Source != "a.tsx" {
+		t.Errorf("tileset mismatch; got name %q, first GID %d and source %q", ts.Name, ts.FirstGID, ts.Source)
+	}
+	if got, want := ts.Image.Source, "sub/b.png"; got != want {
+		t.Errorf("image source mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	const valid = `<map version="1.0" orientation="orthogonal" width="1" height="1" tilewidth="32" tileheight="32"/>`
+	dir := writeFiles(t, map[string]string{
+		"a.tmx":       valid,
+		"B.TMX":       valid,
+		"broken.tmx":  `<map`,
+		"notes.txt":   "not a map",
+		"sub/c.tmx":   valid,
+		"tiles.tsx":   `<tileset name="tiles"/>`,
+		"missing.tmx": `<map version="1.0"><tileset firstgid="1" source="missing.tsx"/></map>`,
+	})
+	maps, err := OpenDir(dir)
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("error mismatch; expected ErrorList, got %v", err)
+	}
+	// Files which fail to parse are reported, and the other maps are returned.
+	if got, want := len(errs), 2; got != want {
+		t.Errorf("number of errors mismatch; expected %d, got %d", want, got)
+	}
+	if got, want := len(maps), 2; got != want {
+		t.Errorf("number of maps mismatch; expected %d, got %d", want, got)
+	}
+	for _, name := range []string{"a.tmx", "B.TMX"} {
+		if maps[name] == nil {
+			t.Errorf("map %q missing", name)
+		}
+	}
+	if !strings.Contains(err.Error(), "broken.tmx") || !strings.Contains(err.Error(), "missing.tmx") {
+		t.Errorf("error message mismatch; expected the names of the broken files, got %q", err)
+	}
+}
+
+func TestOpenDirEmpty(t *testing.T) {
+	maps, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 0 {
+		t.Errorf("number of maps mismatch; expected 0, got %d", len(maps))
+	}
+	if _, err := OpenDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestDataDecodeBase64Unpadded(t *testing.T) {
+	padded := encodeGIDs([]uint32{1, 2, 3, 4}, "base64", "")
+	unpadded := strings.TrimRight(padded, "=")
+	if unpadded == padded {
+		t.Fatal("expected padded base64 data")
+	}
+	for _, data := range []string{padded, unpadded, "\n   " + unpadded + "\n  "} {
+		m, err := NewFile(strings.NewReader(layerMapSource(`encoding="base64"`, data)))
+		if err != nil {
+			t.Errorf("%q: unexpected error; %v", data, err)
+			continue
+		}
+		checkGIDs(t, m, []int{1, 2, 3, 4})
+	}
+	if _, err := decodeBase64String("AQ=A"); err == nil {
+		t.Error("expected error for invalid base64 data")
+	}
+}
+
+func TestDataDecodeZstd(t *testing.T) {
+	// zstd compression is not supported, and is reported rather than being
+	// mistaken for another compression method.
+	data := encodeGIDs([]uint32{1, 2, 3, 4}, "base64", "")
+	src := layerMapSource(`encoding="base64" compression="zstd"`, data)
+	for _, lenient := range []bool{false, true} {
+		var opts []Option
+		if lenient {
+			opts = append(opts, WithLenient())
+		}
+		_, err := NewFile(strings.NewReader(src), opts...)
+		if err == nil || !strings.Contains(err.Error(), "zstd") {
+			t.Errorf("lenient=%v: error mismatch; expected unsupported zstd compression, got %v", lenient, err)
+		}
+	}
+}
+
+func TestDataDecodeCase(t *testing.T) {
+	gids := []uint32{1, 2, 3, 4}
+	golden := []struct {
+		encoding, compression string
+		attrs                 string
+	}{
+		{encoding: "csv", attrs: `encoding="CSV"`},
+		{encoding: "base64", attrs: `encoding="Base64"`},
+		{encoding: "base64", compression: "gzip", attrs: `encoding="BASE64" compression="GZip"`},
+		{encoding: "base64", compression: "zlib", attrs: `encoding=" base64 " compression=" ZLIB "`},
+	}
+	for _, g := range golden {
+		src := layerMapSource(g.attrs, encodeGIDs(gids, g.encoding, g.compression))
+		m, err := NewFile(strings.NewReader(src))
+		if err != nil {
+			t.Errorf("%s: unexpected error; %v", g.attrs, err)
+			continue
+		}
+		checkGIDs(t, m, []int{1, 2, 3, 4})
+		data := m.Layers[0].Data
+		if data.Encoding != g.encoding || data.Compression != g.compression {
+			t.Errorf("%s: encoding and compression mismatch; expected %q and %q, got %q and %q", g.attrs, g.encoding, g.compression, data.Encoding, data.Compression)
+		}
+	}
+	// The chunks of infinite maps are decoded likewise.
+	src := strings.Replace(chunkedMapSource(1, 1, "base64", "zlib"), `encoding="base64" compression="zlib"`, `encoding="BASE64" compression="ZLIB"`, 1)
+	m, err := NewFile(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gid := m.Layers[0].GetGID(1, 0); gid != 2 {
+		t.Errorf("GID mismatch; expected 2, got %d", gid)
+	}
+}
+
+func TestDataDecodeBase64TileFallback(t *testing.T) {
+	tiles := `<tile gid="1"/><tile gid="2"/><tile gid="3"/><tile gid="4"/>`
+	src := layerMapSource(`encoding="base64"`, tiles)
+	if _, err := NewFile(strings.NewReader(src)); err == nil {
+		t.Error("expected error for invalid base64 data in strict mode")
+	}
+	// The <tile> elements are used in lenient mode.
+	m, err := NewFile(strings.NewReader(src), WithLenient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGIDs(t, m, []int{1, 2, 3, 4})
+	if len(m.Warnings) != 1 {
+		t.Errorf("number of warnings mismatch; expected 1, got %d", len(m.Warnings))
+	}
+	// The <tile> elements must cover every cell.
+	src = layerMapSource(`encoding="base64"`, `<tile gid="1"/><tile gid="2"/><tile gid="3"/>`)
+	if _, err := NewFile(strings.NewReader(src), WithLenient()); err == nil {
+		t.Error("expected error for invalid base64 data with too few tile elements")
+	}
+}
+
+func TestGIDWithFlip(t *testing.T) {
+	gid := MakeGID(7, true, false, true)
+	if !gid.IsHorizontalFlip() || gid.IsVerticalFlip() || !gid.IsDiagonalFlip() {
+		t.Errorf("flip flags mismatch; expected horizontal and diagonal flip, got %#x", uint32(gid))
+	}
+	if id := gid.GlobalTileID(); id != 7 {
+		t.Errorf("global tile ID mismatch; expected 7, got %d", id)
+	}
+	// The flip flags are replaced rather than combined.
+	gid = gid.WithFlip(false, true, false)
+	if want := GID(7) | FlagVerticalFlip; gid != want {
+		t.Errorf("GID mismatch; expected %#x, got %#x", uint32(want), uint32(gid))
+	}
+	if gid = gid.WithFlip(false, false, false); gid != 7 {
+		t.Errorf("GID mismatch; expected 7, got %#x", uint32(gid))
+	}
+}
+
+func TestDataDecodeBase64Whitespace(t *testing.T) {
+	// split inserts the separator every 4 characters of s.
+	split := func(s, sep string) string {
+		var parts []string
+		for len(s) > 4 {
+			parts = append(parts, s[:4])
+			s = s[4:]
+		}
+		return strings.Join(append(parts, s), sep)
+	}
+	for _, compression := range []string{"", "gzip", "zlib"} {
+		data := encodeGIDs([]uint32{1, 2, 3, 4}, "base64", compression)
+		for _, sep := range []string{"\r\n", "\n", " \t", "\r\n   "} {
+			src := layerMapSource(fmt.Sprintf(`encoding="base64" compression="%s"`, compression), "\r\n   "+split(data, sep)+"\r\n  ")
+			m, err := NewFile(strings.NewReader(src))
+			if err != nil {
+				t.Errorf("compression=%q, separator=%q: unexpected error; %v", compression, sep, err)
+				continue
+			}
+			checkGIDs(t, m, []int{1, 2, 3, 4})
+		}
+	}
+	// Unpadded data with CRLF line endings.
+	data := strings.TrimRight(encodeGIDs([]uint32{1, 2, 3, 5}, "base64", "zlib"), "=")
+	m, err := NewFile(strings.NewReader(layerMapSource(`encoding="base64" compression="zlib"`, split(data, "\r\n"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGIDs(t, m, []int{1, 2, 3, 5})
+}